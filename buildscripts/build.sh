@@ -15,6 +15,9 @@ cd "$DIR"
 GIT_COMMIT="$(git rev-parse HEAD)"
 GIT_DIRTY="$(test -n "`git status --porcelain`" && echo "+CHANGES" || true)"
 
+# Get the build date
+BUILD_DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
 # Fetch the tags before using git rev-list --tags
 git fetch --tags >/dev/null 2>&1
 GIT_TAG="$(git describe --tags $(git rev-list --tags --max-count=1))"
@@ -56,7 +59,8 @@ gox \
     -ldflags \
        "-X main.GitCommit='${GIT_COMMIT}${GIT_DIRTY}' \
         -X main.CtlName='${CTLNAME}' \
-        -X main.Version='${GIT_TAG}'" \
+        -X main.Version='${GIT_TAG}' \
+        -X main.BuildDate='${BUILD_DATE}'" \
     -output "pkg/{{.OS}}_{{.Arch}}/${CTLNAME}" \
     .
 
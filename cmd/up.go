@@ -7,8 +7,8 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"reflect"
+	"runtime/debug"
 	"sort"
 
 	"strings"
@@ -38,11 +38,13 @@ type UpCommand struct {
 	Ui                cli.Ui
 	ShutdownCh        <-chan struct{}
 
-	args       []string
-	maya       *server.MayaServer
-	httpServer *server.HTTPServer
-	logFilter  *logutils.LevelFilter
-	logOutput  io.Writer
+	args          []string
+	maya          *server.MayaServer
+	httpServer    *server.HTTPServer
+	logFilter     *logutils.LevelFilter
+	logSuppressor *server.LogSuppressor
+	crashReporter *server.CrashReporter
+	logOutput     io.Writer
 }
 
 func (c *UpCommand) readMayaConfig() *server.MayaConfig {
@@ -68,21 +70,49 @@ func (c *UpCommand) readMayaConfig() *server.MayaConfig {
 		return nil
 	}
 
-	// Load the configuration
+	mconfig, err := loadMayaConfig(c.Ui, configPath, cmdConfig)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return nil
+	}
+
+	// Set the version info
+	mconfig.Revision = c.Revision
+	mconfig.Version = c.Version
+	mconfig.VersionPrerelease = c.VersionPrerelease
+
+	// Normalize binds, ports, addresses, and advertise
+	if err := mconfig.NormalizeAddrs(); err != nil {
+		c.Ui.Error(err.Error())
+		return nil
+	}
+
+	// Verify the config is sane
+	if err := mconfig.Validate(); err != nil {
+		c.Ui.Error(err.Error())
+		return nil
+	}
+
+	return mconfig
+}
+
+// loadMayaConfig loads the config files at the given paths & merges them
+// over the default config. The MAYA_SERVER_* environment variables are
+// merged next & the CLI provided config is merged last.
+func loadMayaConfig(ui cli.Ui, configPath []string, cmdConfig *server.MayaConfig) (*server.MayaConfig, error) {
 	mconfig := server.DefaultMayaConfig()
 
 	for _, path := range configPath {
 		current, err := server.LoadMayaConfig(path)
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf(
-				"Error loading configuration from %s: %s", path, err))
-			return nil
+			return nil, fmt.Errorf(
+				"Error loading configuration from %s: %s", path, err)
 		}
 
 		// The user asked us to load some config here but we didn't find any,
 		// so we'll complain but continue.
 		if current == nil || reflect.DeepEqual(current, &server.MayaConfig{}) {
-			c.Ui.Warn(fmt.Sprintf("No configuration loaded from %s", path))
+			ui.Warn(fmt.Sprintf("No configuration loaded from %s", path))
 		}
 
 		if mconfig == nil {
@@ -92,36 +122,15 @@ func (c *UpCommand) readMayaConfig() *server.MayaConfig {
 		}
 	}
 
-	// Merge any CLI options over config file options
-	mconfig = mconfig.Merge(cmdConfig)
-
-	// Set the version info
-	mconfig.Revision = c.Revision
-	mconfig.Version = c.Version
-	mconfig.VersionPrerelease = c.VersionPrerelease
-
-	// Normalize binds, ports, addresses, and advertise
-	if err := mconfig.NormalizeAddrs(); err != nil {
-		c.Ui.Error(err.Error())
-		return nil
-	}
-
-	// Verify the paths are absolute.
-	dirs := map[string]string{
-		"data-dir": mconfig.DataDir,
-	}
-	for k, dir := range dirs {
-		if dir == "" {
-			continue
-		}
-
-		if !filepath.IsAbs(dir) {
-			c.Ui.Error(fmt.Sprintf("%s must be given as an absolute path: got %v", k, dir))
-			return nil
-		}
+	// Merge the environment over config file options
+	mconfig, err := server.MergeMayaConfigEnv(mconfig, os.Environ())
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error loading configuration from environment: %s", err)
 	}
 
-	return mconfig
+	// Merge any CLI options over config file & environment options
+	return mconfig.Merge(cmdConfig), nil
 }
 
 // setupLoggers is used to setup the logGate, logWriter, and our logOutput
@@ -162,6 +171,15 @@ func (c *UpCommand) setupLoggers(mconfig *server.MayaConfig) (*gatedwriter.Write
 	} else {
 		logOutput = io.MultiWriter(c.logFilter, logWriter)
 	}
+
+	// Report crashes along with the recent logs
+	c.crashReporter = server.NewCrashReporter(mconfig, logWriter, logOutput)
+
+	// Suppress repeated log lines before they reach any of the sinks
+	if mconfig.LogSuppression != nil && mconfig.LogSuppression.Enabled {
+		c.logSuppressor = server.NewLogSuppressor(logOutput, mconfig.LogSuppression, c.crashReporter)
+		logOutput = c.logSuppressor
+	}
 	c.logOutput = logOutput
 	log.SetOutput(logOutput)
 	return logGate, logWriter, logOutput
@@ -176,6 +194,7 @@ func (c *UpCommand) setupMayaServer(mconfig *server.MayaConfig, logOutput io.Wri
 		return err
 	}
 	c.maya = maya
+	maya.SetCrashReporter(c.crashReporter)
 
 	// Setup the HTTP server
 	http, err := server.NewHTTPServer(maya, mconfig, logOutput)
@@ -209,6 +228,12 @@ func (c *UpCommand) Run(args []string) int {
 	if logGate == nil {
 		return 1
 	}
+	if c.logSuppressor != nil {
+		defer c.logSuppressor.Stop()
+	}
+
+	// Report crashes before the process exits
+	defer c.handlePanic()
 
 	// Log config files
 	if len(mconfig.Files) > 0 {
@@ -235,6 +260,11 @@ func (c *UpCommand) Run(args []string) int {
 	info["version"] = fmt.Sprintf("%s%s", mconfig.Version, mconfig.VersionPrerelease)
 	info["log level"] = mconfig.LogLevel
 	info["region"] = fmt.Sprintf("%s (DC: %s)", mconfig.Region, mconfig.Datacenter)
+	info["cluster id"] = c.maya.ClusterID()
+	info["node id"] = c.maya.NodeID()
+	if gates := featureGatesInfo(mconfig); gates != "" {
+		info["feature gates"] = gates
+	}
 
 	// Sort the keys for output
 	infoKeys := make([]string, 0, len(info))
@@ -329,6 +359,40 @@ WAIT:
 	}
 }
 
+// handlePanic reports a panic of the command's own goroutine, then resumes
+// it. The panics of the HTTP handlers & of the background goroutines are
+// reported by the crash reporter where they happen. This must be deferred
+// directly so that it can recover the panic.
+func (c *UpCommand) handlePanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if path := c.crashReporter.Report(r, debug.Stack()); path != "" {
+		c.Ui.Error(fmt.Sprintf("Maya server crashed: %v, crash report written to %s", r, path))
+	} else {
+		c.Ui.Error(fmt.Sprintf("Maya server crashed: %v", r))
+	}
+	panic(r)
+}
+
+// featureGatesInfo returns the feature gates in a sorted, human readable
+// form, or an empty string if no feature gate is set.
+func featureGatesInfo(mconfig *server.MayaConfig) string {
+	features, err := mconfig.Features()
+	if err != nil || len(features) == 0 {
+		return ""
+	}
+
+	gates := make([]string, 0, len(features))
+	for name, enabled := range features {
+		gates = append(gates, fmt.Sprintf("%s=%t", name, enabled))
+	}
+	sort.Strings(gates)
+	return strings.Join(gates, ", ")
+}
+
 // handleReload is invoked when we should reload our configs, e.g. SIGHUP
 func (c *UpCommand) handleReload(mconfig *server.MayaConfig) *server.MayaConfig {
 	c.Ui.Output("Reloading Maya server configuration...")
@@ -350,6 +414,14 @@ func (c *UpCommand) handleReload(mconfig *server.MayaConfig) *server.MayaConfig
 		// Keep the current log level
 		newConf.LogLevel = mconfig.LogLevel
 	}
+
+	// Reload the TLS certificates served by the HTTP server
+	if err := c.httpServer.ReloadTLS(newConf.TLSConfig); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to reload TLS configuration: %v", err))
+
+		// Keep the current TLS configuration
+		newConf.TLSConfig = mconfig.TLSConfig
+	}
 	return newConf
 }
 
@@ -368,6 +440,12 @@ Usage: mayaserver up [options]
   files used, but a subset of the options may also be passed directly
   as CLI arguments, listed below.
 
+  Most config file options may also be set through a MAYA_SERVER_*
+  environment variable, e.g. MAYA_SERVER_BIND, MAYA_SERVER_DATA_DIR,
+  MAYA_SERVER_LOG_LEVEL or MAYA_SERVER_PORTS_HTTP. Environment variables
+  take precedence over config files, CLI arguments take precedence
+  over both.
+
 General Options :
 
   -bind=<addr>
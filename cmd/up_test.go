@@ -3,10 +3,12 @@ package cmd
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/mitchellh/cli"
+	"github.com/openebs/mayaserver/server"
 )
 
 func TestCommand_Implements(t *testing.T) {
@@ -64,3 +66,61 @@ func TestCommand_Args(t *testing.T) {
 		}
 	}
 }
+
+func TestFeatureGatesInfo(t *testing.T) {
+	mconfig := &server.MayaConfig{}
+	if info := featureGatesInfo(mconfig); info != "" {
+		t.Fatalf("expected no info, got: %q", info)
+	}
+
+	mconfig.FeatureGates = []string{"Snapshots=true", "Raft=false"}
+	if info := featureGatesInfo(mconfig); info != "Raft=false, Snapshots=true" {
+		t.Fatalf("bad info: %q", info)
+	}
+}
+
+func TestCommand_HandlePanic(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ui := new(cli.MockUi)
+	mconfig := server.DefaultMayaConfig()
+	mconfig.DataDir = tmpDir
+	logWriter := server.NewLogWriter(16)
+	logWriter.Write([]byte("[INFO] mayaserver: about to crash"))
+	cmd := &UpCommand{
+		Ui:            ui,
+		crashReporter: server.NewCrashReporter(mconfig, logWriter, ioutil.Discard),
+	}
+
+	// The panic is resumed once reported
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be resumed, got: %v", r)
+			}
+		}()
+		defer cmd.handlePanic()
+		panic("boom")
+	}()
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, "crash-*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a crash report, got: %v, err: %v", files, err)
+	}
+	buf, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(buf), "about to crash") {
+		t.Fatalf("expected recent logs in crash report, got: %s", buf)
+	}
+
+	out := ui.ErrorWriter.String()
+	if !strings.Contains(out, "Maya server crashed: boom, crash report written to "+files[0]) {
+		t.Fatalf("bad: %s", out)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/openebs/mayaserver/server"
+	"github.com/openebs/mayaserver/util/flag-helpers"
+)
+
+// ValidateCommand is a cli implementation that validates Maya server's
+// configuration without starting the server.
+type ValidateCommand struct {
+	Meta
+}
+
+func (c *ValidateCommand) Help() string {
+	helpText := `
+Usage: mayaserver validate [options]
+
+  Loads & merges the config files exactly like the up command does, and
+  reports every problem found in the resulting configuration. The server
+  is not started.
+
+Validate Options:
+
+  -config=<path>
+    The path to either a single config file or a directory of config
+    files to validate. This option may be specified multiple times, in
+    which case the configs are merged in the given order.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ValidateCommand) Synopsis() string {
+	return "Validates Maya server configuration"
+}
+
+func (c *ValidateCommand) Run(args []string) int {
+	var configPath []string
+
+	flags := c.Meta.FlagSet("validate", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.Var((*flaghelper.StringFlag)(&configPath), "config", "config")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(configPath) == 0 {
+		c.Ui.Error("At least one -config must be provided")
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	mconfig, err := loadMayaConfig(c.Ui, configPath, &server.MayaConfig{})
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	var errs []error
+	if err := mconfig.NormalizeAddrs(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := mconfig.Validate(); err != nil {
+		if merr, ok := err.(*multierror.Error); ok {
+			errs = append(errs, merr.Errors...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		c.Ui.Error(fmt.Sprintf("Configuration has %d error(s):", len(errs)))
+		for _, err := range errs {
+			c.Ui.Error(fmt.Sprintf("  * %v", err))
+		}
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Configuration is valid (%s)", strings.Join(mconfig.Files, ", ")))
+	return 0
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestValidateCommand_Implements(t *testing.T) {
+	var _ cli.Command = &ValidateCommand{}
+}
+
+func TestValidateCommand_Run(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	valid := filepath.Join(tmpDir, "valid.hcl")
+	err = ioutil.WriteFile(valid, []byte(`
+data_dir = "/var/lib/mayaserver"
+service_provider = "nomad"
+ports {
+	http = 5656
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	invalid := filepath.Join(tmpDir, "invalid.hcl")
+	err = ioutil.WriteFile(invalid, []byte(`
+data_dir = "relative/dir"
+log_level = "chatty"
+service_provider = "unicorns"
+ports {
+	http = 70000
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	type tcase struct {
+		args   []string
+		code   int
+		errOut []string
+	}
+	tcases := []tcase{
+		{
+			[]string{"-config=" + valid},
+			0,
+			nil,
+		},
+		{
+			[]string{},
+			1,
+			[]string{"At least one -config must be provided"},
+		},
+		{
+			[]string{"-config=" + filepath.Join(tmpDir, "missing.hcl")},
+			1,
+			[]string{"Error loading configuration"},
+		},
+		{
+			[]string{"-config=" + valid, "-config=" + invalid},
+			1,
+			[]string{
+				"Configuration has 4 error(s)",
+				"ports -> http must be between 1 and 65535",
+				"data-dir must be given as an absolute path",
+				"Invalid log level: CHATTY",
+				"Unknown service provider: unicorns",
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		ui := new(cli.MockUi)
+		cmd := &ValidateCommand{Meta: Meta{Ui: ui}}
+
+		if code := cmd.Run(tc.args); code != tc.code {
+			t.Fatalf("args: %v\nexpected exit: %d, got: %d\n\n%s",
+				tc.args, tc.code, code, ui.ErrorWriter.String())
+		}
+
+		out := ui.ErrorWriter.String()
+		for _, expect := range tc.errOut {
+			if !strings.Contains(out, expect) {
+				t.Fatalf("expect to find %q\n\n%s", expect, out)
+			}
+		}
+	}
+}
@@ -2,9 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"runtime"
+	"strings"
 
 	"github.com/mitchellh/cli"
+	"github.com/openebs/mayaserver/structs"
 )
 
 // VersionCommand is a cli implementation that prints the version.
@@ -12,14 +17,58 @@ type VersionCommand struct {
 	Revision          string
 	Version           string
 	VersionPrerelease string
+	BuildDate         string
 	Ui                cli.Ui
 }
 
+// VersionInfo is the build metadata printed by the version command
+type VersionInfo struct {
+	Version           string
+	VersionPrerelease string
+	Revision          string
+	BuildDate         string
+	GoVersion         string
+	APIVersions       map[string]int
+}
+
 func (c *VersionCommand) Help() string {
-	return ""
+	helpText := `
+Usage: mayaserver version [options]
+
+  Prints the Mayaserver version along with its build metadata & the
+  supported API versions.
+
+Version Options:
+
+  -json
+    Output the version information in JSON format.
+`
+	return strings.TrimSpace(helpText)
 }
 
-func (c *VersionCommand) Run(_ []string) int {
+func (c *VersionCommand) Run(args []string) int {
+	var jsonOutput bool
+
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&jsonOutput, "json", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	info := c.versionInfo()
+
+	if jsonOutput {
+		out, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error formatting version: %s", err))
+			return 1
+		}
+		c.Ui.Output(string(out))
+		return 0
+	}
+
 	var versionString bytes.Buffer
 
 	fmt.Fprintf(&versionString, "Mayaserver v%s", c.Version)
@@ -32,9 +81,31 @@ func (c *VersionCommand) Run(_ []string) int {
 	}
 
 	c.Ui.Output(versionString.String())
+	if c.BuildDate != "" {
+		c.Ui.Output(fmt.Sprintf("Build Date: %s", c.BuildDate))
+	}
+	c.Ui.Output(fmt.Sprintf("Go Version: %s", info.GoVersion))
+	c.Ui.Output(fmt.Sprintf("API Version: %d.%d",
+		info.APIVersions[structs.APIMajorVersion],
+		info.APIVersions[structs.APIMinorVersion]))
 	return 0
 }
 
+// versionInfo compiles the version information of this build
+func (c *VersionCommand) versionInfo() *VersionInfo {
+	return &VersionInfo{
+		Version:           c.Version,
+		VersionPrerelease: c.VersionPrerelease,
+		Revision:          c.Revision,
+		BuildDate:         c.BuildDate,
+		GoVersion:         runtime.Version(),
+		APIVersions: map[string]int{
+			structs.APIMajorVersion: structs.ApiMajorVersion,
+			structs.APIMinorVersion: structs.ApiMinorVersion,
+		},
+	}
+}
+
 func (c *VersionCommand) Synopsis() string {
 	return "Prints the Mayaserver version"
 }
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"encoding/json"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/mitchellh/cli"
@@ -9,3 +12,68 @@ import (
 func TestVersionCommand_implements(t *testing.T) {
 	var _ cli.Command = &VersionCommand{}
 }
+
+func TestVersionCommand_Run(t *testing.T) {
+	ui := new(cli.MockUi)
+	cmd := &VersionCommand{
+		Revision:          "abc123",
+		Version:           "0.1.0",
+		VersionPrerelease: "dev",
+		BuildDate:         "2017-03-01T10:00:00Z",
+		Ui:                ui,
+	}
+
+	if code := cmd.Run([]string{}); code != 0 {
+		t.Fatalf("expected exit 0, got: %d", code)
+	}
+
+	out := ui.OutputWriter.String()
+	for _, expect := range []string{
+		"Mayaserver v0.1.0-dev (abc123)",
+		"Build Date: 2017-03-01T10:00:00Z",
+		"Go Version: " + runtime.Version(),
+		"API Version: 1.1",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Fatalf("expect to find %q\n\n%s", expect, out)
+		}
+	}
+}
+
+func TestVersionCommand_JSON(t *testing.T) {
+	ui := new(cli.MockUi)
+	cmd := &VersionCommand{
+		Revision:          "abc123",
+		Version:           "0.1.0",
+		VersionPrerelease: "dev",
+		Ui:                ui,
+	}
+
+	if code := cmd.Run([]string{"-json"}); code != 0 {
+		t.Fatalf("expected exit 0, got: %d", code)
+	}
+
+	var info VersionInfo
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &info); err != nil {
+		t.Fatalf("err: %v\n\n%s", err, ui.OutputWriter.String())
+	}
+
+	if info.Version != "0.1.0" || info.VersionPrerelease != "dev" || info.Revision != "abc123" {
+		t.Fatalf("bad: %#v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Fatalf("bad go version: %s", info.GoVersion)
+	}
+	if info.APIVersions["api.major"] != 1 || info.APIVersions["api.minor"] != 1 {
+		t.Fatalf("bad api versions: %v", info.APIVersions)
+	}
+}
+
+func TestVersionCommand_InvalidFlag(t *testing.T) {
+	ui := new(cli.MockUi)
+	cmd := &VersionCommand{Ui: ui}
+
+	if code := cmd.Run([]string{"-nope"}); code != 1 {
+		t.Fatalf("expected exit 1, got: %d", code)
+	}
+}
@@ -33,6 +33,11 @@ func Commands(metaPtr *cmd.Meta) map[string]cli.CommandFactory {
 				ShutdownCh:        make(chan struct{}),
 			}, nil
 		},
+		"validate": func() (cli.Command, error) {
+			return &cmd.ValidateCommand{
+				Meta: meta,
+			}, nil
+		},
 		"version": func() (cli.Command, error) {
 			ver := Version
 			rel := VersionPrerelease
@@ -51,6 +56,7 @@ func Commands(metaPtr *cmd.Meta) map[string]cli.CommandFactory {
 				Revision:          GitCommit,
 				Version:           ver,
 				VersionPrerelease: rel,
+				BuildDate:         BuildDate,
 				Ui:                meta.Ui,
 			}, nil
 		},
@@ -25,12 +25,14 @@ func Run(args []string) int {
 
 func RunCustom(args []string, commands map[string]cli.CommandFactory) int {
 	// Get the command line args. We shortcut "--version" and "-v" to
-	// just show the version.
-	for _, arg := range args {
+	// just show the version. The shortcut itself is dropped so that the
+	// remaining args are passed on as version options.
+	for i, arg := range args {
 		if arg == "-v" || arg == "-version" || arg == "--version" {
-			newArgs := make([]string, len(args)+1)
-			newArgs[0] = "version"
-			copy(newArgs[1:], args)
+			newArgs := make([]string, 0, len(args))
+			newArgs = append(newArgs, "version")
+			newArgs = append(newArgs, args[:i]...)
+			newArgs = append(newArgs, args[i+1:]...)
 			args = newArgs
 			break
 		}
@@ -2,9 +2,11 @@ region = "BANG-EAST"
 datacenter = "dc2"
 name = "my-vsm"
 data_dir = "/tmp/mayaserver"
+cluster_id = "maya-prod"
 log_level = "ERR"
 bind_addr = "192.168.0.1"
 enable_debug = true
+service_provider = "nomad"
 ports {
 	http = 1234
 }
@@ -13,10 +15,48 @@ addresses {
 }
 advertise {
 }
+http {
+	slow_request_threshold = "2s"
+	rate_limit = 2.5
+	rate_limit_burst = 10
+	max_concurrent_requests = 64
+	read_timeout = "10s"
+	write_timeout = "20s"
+	idle_timeout = "1m"
+	max_header_bytes = 65536
+}
+tls {
+	http = true
+	verify_incoming = true
+	ca_file = "/etc/mayaserver/ca.pem"
+	cert_file = "/etc/mayaserver/server.pem"
+	key_file = "/etc/mayaserver/server-key.pem"
+}
+cors {
+	allowed_origins = ["https://dashboard.openebs.io"]
+	allowed_methods = ["GET", "POST"]
+	allowed_headers = ["Content-Type"]
+	max_age = "10m"
+}
+meta_data {
+	instance_id = "i-maya01"
+	availability_zone = "bang-east-1a"
+	local_ipv4 = "192.168.0.1"
+}
 leave_on_interrupt = true
 leave_on_terminate = true
+log_suppression {
+	enabled = true
+	window = "30s"
+	subsystems {
+		http = "1m"
+		mayaserver = "0s"
+	}
+}
 enable_syslog = true
 syslog_facility = "LOCAL1"
+crash_report_webhook = "https://crash.example.com/mayaserver"
+feature_gates = ["Snapshots=true", "Raft=false"]
 http_api_response_headers {
 	Access-Control-Allow-Origin = "*"
 }
@@ -1,15 +1,30 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/logutils"
+	"github.com/openebs/mayaserver/util/tlsutil"
+)
+
+const (
+	// NomadServiceProvider is the service provider name of Nomad
+	NomadServiceProvider = "nomad"
+
+	// K8sServiceProvider is the service provider name of Kubernetes
+	K8sServiceProvider = "kubernetes"
 )
 
 // MayaConfig is the configuration for Maya server.
@@ -28,6 +43,11 @@ type MayaConfig struct {
 	// DataDir is the directory to store Maya server's state in
 	DataDir string `mapstructure:"data_dir"`
 
+	// ClusterID identifies the Maya installation. It must be set to the
+	// same value on all the Maya servers of an installation. If not set,
+	// an ID local to this server is generated & persisted in data_dir.
+	ClusterID string `mapstructure:"cluster_id"`
+
 	// LogLevel is the level of the logs to putout
 	LogLevel string `mapstructure:"log_level"`
 
@@ -56,18 +76,43 @@ type MayaConfig struct {
 	// AdvertiseAddrs is used to control the addresses we advertise.
 	AdvertiseAddrs *AdvertiseAddrs `mapstructure:"advertise"`
 
+	// HTTP is used to tune the HTTP API server.
+	HTTP *HTTPConfig `mapstructure:"http"`
+
+	// TLSConfig is used to secure the HTTP API with TLS.
+	TLSConfig *TLSConfig `mapstructure:"tls"`
+
+	// CORS is used to let browser based dashboards call the HTTP API.
+	CORS *CORSConfig `mapstructure:"cors"`
+
+	// MetaData is used to override the values served by the EC2 style
+	// meta-data endpoints.
+	MetaData *MetaData `mapstructure:"meta_data"`
+
 	// LeaveOnInt is used to gracefully leave on the interrupt signal
 	LeaveOnInt bool `mapstructure:"leave_on_interrupt"`
 
 	// LeaveOnTerm is used to gracefully leave on the terminate signal
 	LeaveOnTerm bool `mapstructure:"leave_on_terminate"`
 
+	// LogSuppression is used to suppress repeated identical log lines
+	LogSuppression *LogSuppression `mapstructure:"log_suppression"`
+
 	// EnableSyslog is used to enable sending logs to syslog
 	EnableSyslog bool `mapstructure:"enable_syslog"`
 
 	// SyslogFacility is used to control the syslog facility used.
 	SyslogFacility string `mapstructure:"syslog_facility"`
 
+	// CrashReportWebhook is an URL the crash reports are posted to, in
+	// addition to being written to the data_dir.
+	CrashReportWebhook string `mapstructure:"crash_report_webhook"`
+
+	// FeatureGates turns experimental subsystems on or off. Each entry
+	// is of the form Name=true or Name=false, later entries override
+	// earlier ones.
+	FeatureGates []string `mapstructure:"feature_gates"`
+
 	// NomadConfig is used to communicate with Nomad agent.
 	//NomadConfig *nomad.Config `mapstructure:"nomad_config"`
 
@@ -103,6 +148,107 @@ type AdvertiseAddrs struct {
 	HTTP string `mapstructure:"http"`
 }
 
+// LogSuppression is used to keep the logs usable when identical lines are
+// logged at a high frequency, e.g. while retrying a failing operation.
+type LogSuppression struct {
+	// Enabled turns on the suppression of duplicate log lines
+	Enabled bool `mapstructure:"enabled"`
+
+	// Window is the duration for which repetitions of a log line are
+	// suppressed after it has been logged. A notice with the number of
+	// suppressed lines is logged once the window expires.
+	Window time.Duration `mapstructure:"window"`
+
+	// Subsystems overrides the Window for the log lines of a subsystem,
+	// e.g. http. A zero window disables the suppression for a subsystem.
+	Subsystems map[string]time.Duration `mapstructure:"subsystems"`
+}
+
+// HTTPConfig is used to tune the HTTP API server.
+type HTTPConfig struct {
+	// SlowRequestThreshold is the latency above which a request is logged
+	// at WARN level along with the time spent in each of its phases.
+	// Slow requests are not logged if this is zero.
+	SlowRequestThreshold time.Duration `mapstructure:"slow_request_threshold"`
+
+	// RateLimit is the number of requests per second a client, identified
+	// by its IP address, may send. Clients are not rate limited if this
+	// is zero.
+	RateLimit float64 `mapstructure:"rate_limit"`
+
+	// RateLimitBurst is the number of requests a client may send at once
+	// on top of the RateLimit. Defaults to the RateLimit.
+	RateLimitBurst int `mapstructure:"rate_limit_burst"`
+
+	// MaxConcurrentRequests caps the number of requests served at the
+	// same time. Requests are not capped if this is zero.
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+
+	// ReadTimeout is the maximum duration for reading an entire request,
+	// including its body.
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+
+	// WriteTimeout is the maximum duration before timing out the write
+	// of a response.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+
+	// IdleTimeout is the maximum duration to wait for the next request
+	// on a keep-alive connection.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+
+	// MaxHeaderBytes is the maximum size of the request headers.
+	MaxHeaderBytes int `mapstructure:"max_header_bytes"`
+}
+
+// CORSConfig is used to control the cross-origin requests browsers may make
+// to the HTTP API. CORS is disabled unless AllowedOrigins is set.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the HTTP API, e.g.
+	// https://dashboard.example.com. * allows any origin.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	// Defaults to GET, HEAD, POST, PUT & DELETE.
+	AllowedMethods []string `mapstructure:"allowed_methods"`
+
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests. Defaults to Content-Type & X-Request-ID.
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
+
+	// MaxAge is how long browsers may cache the result of a preflight
+	// request.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// TLSConfig is used to control the TLS settings of Maya server's network
+// services.
+type TLSConfig struct {
+	// EnableHTTP enables TLS for the HTTP API
+	EnableHTTP bool `mapstructure:"http"`
+
+	// VerifyIncoming requires & verifies client certificates of incoming
+	// connections against the CA file.
+	VerifyIncoming bool `mapstructure:"verify_incoming"`
+
+	// CAFile is a path to the certificate authority file.
+	CAFile string `mapstructure:"ca_file"`
+
+	// CertFile is the path to the TLS certificate served by Maya server.
+	CertFile string `mapstructure:"cert_file"`
+
+	// KeyFile is the path to the private key of CertFile.
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// MetaData is used to control the values served by the EC2 style
+// meta-data endpoints. Any value that is not set is derived from the
+// rest of the configuration, or falls back to a generic default.
+type MetaData struct {
+	InstanceID       string `mapstructure:"instance_id"`
+	AvailabilityZone string `mapstructure:"availability_zone"`
+	LocalIPv4        string `mapstructure:"local_ipv4"`
+}
+
 // DefaultMayaConfig is a the baseline configuration for Maya server
 func DefaultMayaConfig() *MayaConfig {
 	return &MayaConfig{
@@ -115,6 +261,18 @@ func DefaultMayaConfig() *MayaConfig {
 		},
 		Addresses:      &Addresses{},
 		AdvertiseAddrs: &AdvertiseAddrs{},
+		TLSConfig:      &TLSConfig{},
+		CORS:           &CORSConfig{},
+		MetaData:       &MetaData{},
+		HTTP: &HTTPConfig{
+			ReadTimeout:    30 * time.Second,
+			WriteTimeout:   60 * time.Second,
+			IdleTimeout:    2 * time.Minute,
+			MaxHeaderBytes: 1 << 20,
+		},
+		LogSuppression: &LogSuppression{
+			Window: 10 * time.Second,
+		},
 		SyslogFacility: "LOCAL0",
 	}
 }
@@ -169,6 +327,9 @@ func (mc *MayaConfig) Merge(b *MayaConfig) *MayaConfig {
 	if b.EnableDebug {
 		result.EnableDebug = true
 	}
+	if b.ServiceProvider != "" {
+		result.ServiceProvider = b.ServiceProvider
+	}
 	if b.LeaveOnInt {
 		result.LeaveOnInt = true
 	}
@@ -181,6 +342,15 @@ func (mc *MayaConfig) Merge(b *MayaConfig) *MayaConfig {
 	if b.SyslogFacility != "" {
 		result.SyslogFacility = b.SyslogFacility
 	}
+	if b.CrashReportWebhook != "" {
+		result.CrashReportWebhook = b.CrashReportWebhook
+	}
+	if b.ClusterID != "" {
+		result.ClusterID = b.ClusterID
+	}
+	if len(b.FeatureGates) > 0 {
+		result.FeatureGates = append(append([]string{}, mc.FeatureGates...), b.FeatureGates...)
+	}
 
 	// Apply the ports config
 	if result.Ports == nil && b.Ports != nil {
@@ -206,6 +376,46 @@ func (mc *MayaConfig) Merge(b *MayaConfig) *MayaConfig {
 		result.AdvertiseAddrs = result.AdvertiseAddrs.Merge(b.AdvertiseAddrs)
 	}
 
+	// Apply the log suppression config
+	if result.LogSuppression == nil && b.LogSuppression != nil {
+		logSuppression := *b.LogSuppression
+		result.LogSuppression = &logSuppression
+	} else if b.LogSuppression != nil {
+		result.LogSuppression = result.LogSuppression.Merge(b.LogSuppression)
+	}
+
+	// Apply the HTTP config
+	if result.HTTP == nil && b.HTTP != nil {
+		httpConfig := *b.HTTP
+		result.HTTP = &httpConfig
+	} else if b.HTTP != nil {
+		result.HTTP = result.HTTP.Merge(b.HTTP)
+	}
+
+	// Apply the TLS config
+	if result.TLSConfig == nil && b.TLSConfig != nil {
+		tlsConfig := *b.TLSConfig
+		result.TLSConfig = &tlsConfig
+	} else if b.TLSConfig != nil {
+		result.TLSConfig = result.TLSConfig.Merge(b.TLSConfig)
+	}
+
+	// Apply the CORS config
+	if result.CORS == nil && b.CORS != nil {
+		cors := *b.CORS
+		result.CORS = &cors
+	} else if b.CORS != nil {
+		result.CORS = result.CORS.Merge(b.CORS)
+	}
+
+	// Apply the meta-data config
+	if result.MetaData == nil && b.MetaData != nil {
+		metaData := *b.MetaData
+		result.MetaData = &metaData
+	} else if b.MetaData != nil {
+		result.MetaData = result.MetaData.Merge(b.MetaData)
+	}
+
 	// Merge config files lists
 	result.Files = append(result.Files, b.Files...)
 
@@ -220,6 +430,50 @@ func (mc *MayaConfig) Merge(b *MayaConfig) *MayaConfig {
 	return &result
 }
 
+// splitList splits the comma separated entries of a list, as set e.g. from
+// an environment variable, & drops the empty ones.
+func splitList(list []string) []string {
+	var result []string
+	for _, entry := range list {
+		for _, v := range strings.Split(entry, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// Features parses the feature gates into a map of gate name to whether
+// it is enabled. An entry may also hold several comma separated gates.
+func (mc *MayaConfig) Features() (map[string]bool, error) {
+	features := make(map[string]bool)
+	for _, gate := range splitList(mc.FeatureGates) {
+		parts := strings.SplitN(gate, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf(
+				"feature_gates must be of the form Name=true|false: got %q", gate)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"feature_gates must be of the form Name=true|false: got %q", gate)
+		}
+		features[strings.TrimSpace(parts[0])] = enabled
+	}
+	return features, nil
+}
+
+// FeatureEnabled returns true if the feature gate of the given name is
+// turned on. Invalid feature gates are treated as turned off.
+func (mc *MayaConfig) FeatureEnabled(name string) bool {
+	features, err := mc.Features()
+	if err != nil {
+		return false
+	}
+	return features[name]
+}
+
 // NormalizeAddrs normalizes Addresses and AdvertiseAddrs to always be
 // initialized and have sane defaults.
 func (mc *MayaConfig) NormalizeAddrs() error {
@@ -237,6 +491,132 @@ func (mc *MayaConfig) NormalizeAddrs() error {
 	return nil
 }
 
+// Validate performs semantic checks of the config. All the problems
+// found are returned as a single multierror.
+func (mc *MayaConfig) Validate() error {
+	var result error
+
+	if mc.Ports != nil {
+		if mc.Ports.HTTP <= 0 || mc.Ports.HTTP > 65535 {
+			result = multierror.Append(result, fmt.Errorf(
+				"ports -> http must be between 1 and 65535: got %d", mc.Ports.HTTP))
+		}
+	}
+
+	// Verify the paths are absolute.
+	dirs := map[string]string{
+		"data-dir": mc.DataDir,
+	}
+	for k, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(dir) {
+			result = multierror.Append(result, fmt.Errorf(
+				"%s must be given as an absolute path: got %v", k, dir))
+		}
+	}
+
+	if mc.LogLevel != "" {
+		filter := LevelFilter()
+		minLevel := logutils.LogLevel(strings.ToUpper(mc.LogLevel))
+		if !ValidateLevelFilter(minLevel, filter) {
+			result = multierror.Append(result, fmt.Errorf(
+				"Invalid log level: %s. Valid log levels are: %v", minLevel, filter.Levels))
+		}
+	}
+
+	switch mc.ServiceProvider {
+	case "", NomadServiceProvider, K8sServiceProvider:
+	default:
+		result = multierror.Append(result, fmt.Errorf(
+			"Unknown service provider: %s. Valid service providers are: %v",
+			mc.ServiceProvider, []string{NomadServiceProvider, K8sServiceProvider}))
+	}
+
+	if mc.HTTP != nil {
+		if mc.HTTP.SlowRequestThreshold < 0 {
+			result = multierror.Append(result, fmt.Errorf(
+				"http -> slow_request_threshold must not be negative: got %v",
+				mc.HTTP.SlowRequestThreshold))
+		}
+		if mc.HTTP.RateLimit < 0 {
+			result = multierror.Append(result, fmt.Errorf(
+				"http -> rate_limit must not be negative: got %v", mc.HTTP.RateLimit))
+		}
+		if mc.HTTP.RateLimitBurst < 0 {
+			result = multierror.Append(result, fmt.Errorf(
+				"http -> rate_limit_burst must not be negative: got %d", mc.HTTP.RateLimitBurst))
+		}
+		if mc.HTTP.MaxConcurrentRequests < 0 {
+			result = multierror.Append(result, fmt.Errorf(
+				"http -> max_concurrent_requests must not be negative: got %d",
+				mc.HTTP.MaxConcurrentRequests))
+		}
+		timeouts := map[string]time.Duration{
+			"read_timeout":  mc.HTTP.ReadTimeout,
+			"write_timeout": mc.HTTP.WriteTimeout,
+			"idle_timeout":  mc.HTTP.IdleTimeout,
+		}
+		for k, timeout := range timeouts {
+			if timeout < 0 {
+				result = multierror.Append(result, fmt.Errorf(
+					"http -> %s must not be negative: got %v", k, timeout))
+			}
+		}
+		if mc.HTTP.MaxHeaderBytes < 0 {
+			result = multierror.Append(result, fmt.Errorf(
+				"http -> max_header_bytes must not be negative: got %d", mc.HTTP.MaxHeaderBytes))
+		}
+	}
+
+	if mc.CORS != nil {
+		for _, origin := range splitList(mc.CORS.AllowedOrigins) {
+			if origin == "*" {
+				continue
+			}
+			if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+				result = multierror.Append(result, fmt.Errorf(
+					"cors -> allowed_origins must be * or of the form scheme://host[:port]: got %q", origin))
+			}
+		}
+		if mc.CORS.MaxAge < 0 {
+			result = multierror.Append(result, fmt.Errorf(
+				"cors -> max_age must not be negative: got %v", mc.CORS.MaxAge))
+		}
+	}
+
+	if strings.ContainsAny(mc.ClusterID, " \t\r\n") {
+		result = multierror.Append(result, fmt.Errorf(
+			"cluster_id must not contain whitespace: got %q", mc.ClusterID))
+	}
+
+	if mc.CrashReportWebhook != "" {
+		if u, err := url.Parse(mc.CrashReportWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			result = multierror.Append(result, fmt.Errorf(
+				"crash_report_webhook must be an http or https URL: got %q", mc.CrashReportWebhook))
+		}
+	}
+
+	if _, err := mc.Features(); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if mc.TLSConfig != nil && mc.TLSConfig.EnableHTTP {
+		if mc.TLSConfig.CertFile == "" || mc.TLSConfig.KeyFile == "" {
+			result = multierror.Append(result, fmt.Errorf(
+				"tls -> cert_file & key_file are required to enable TLS for http"))
+		}
+		if mc.TLSConfig.VerifyIncoming && mc.TLSConfig.CAFile == "" {
+			result = multierror.Append(result, fmt.Errorf(
+				"tls -> ca_file is required to verify incoming connections"))
+		}
+	}
+
+	return result
+}
+
 // normalizeBind returns a normalized bind address.
 //
 // If addr is set it is used, if not the default bind address is used.
@@ -354,6 +734,132 @@ func (a *AdvertiseAddrs) Merge(b *AdvertiseAddrs) *AdvertiseAddrs {
 	return &result
 }
 
+// Merge is used to merge two log suppression configs together.
+func (a *LogSuppression) Merge(b *LogSuppression) *LogSuppression {
+	result := *a
+
+	if b.Enabled {
+		result.Enabled = true
+	}
+	if b.Window != 0 {
+		result.Window = b.Window
+	}
+	if len(a.Subsystems) > 0 || len(b.Subsystems) > 0 {
+		result.Subsystems = make(map[string]time.Duration)
+		for k, v := range a.Subsystems {
+			result.Subsystems[k] = v
+		}
+		for k, v := range b.Subsystems {
+			result.Subsystems[k] = v
+		}
+	}
+	return &result
+}
+
+// Merge is used to merge two HTTP configs together.
+func (a *HTTPConfig) Merge(b *HTTPConfig) *HTTPConfig {
+	result := *a
+
+	if b.SlowRequestThreshold != 0 {
+		result.SlowRequestThreshold = b.SlowRequestThreshold
+	}
+	if b.RateLimit != 0 {
+		result.RateLimit = b.RateLimit
+	}
+	if b.RateLimitBurst != 0 {
+		result.RateLimitBurst = b.RateLimitBurst
+	}
+	if b.MaxConcurrentRequests != 0 {
+		result.MaxConcurrentRequests = b.MaxConcurrentRequests
+	}
+	if b.ReadTimeout != 0 {
+		result.ReadTimeout = b.ReadTimeout
+	}
+	if b.WriteTimeout != 0 {
+		result.WriteTimeout = b.WriteTimeout
+	}
+	if b.IdleTimeout != 0 {
+		result.IdleTimeout = b.IdleTimeout
+	}
+	if b.MaxHeaderBytes != 0 {
+		result.MaxHeaderBytes = b.MaxHeaderBytes
+	}
+	return &result
+}
+
+// Merge is used to merge two CORS configs together.
+func (a *CORSConfig) Merge(b *CORSConfig) *CORSConfig {
+	result := *a
+
+	if len(b.AllowedOrigins) > 0 {
+		result.AllowedOrigins = b.AllowedOrigins
+	}
+	if len(b.AllowedMethods) > 0 {
+		result.AllowedMethods = b.AllowedMethods
+	}
+	if len(b.AllowedHeaders) > 0 {
+		result.AllowedHeaders = b.AllowedHeaders
+	}
+	if b.MaxAge != 0 {
+		result.MaxAge = b.MaxAge
+	}
+	return &result
+}
+
+// Merge is used to merge two TLS configs together.
+func (a *TLSConfig) Merge(b *TLSConfig) *TLSConfig {
+	result := *a
+
+	if b.EnableHTTP {
+		result.EnableHTTP = true
+	}
+	if b.VerifyIncoming {
+		result.VerifyIncoming = true
+	}
+	if b.CAFile != "" {
+		result.CAFile = b.CAFile
+	}
+	if b.CertFile != "" {
+		result.CertFile = b.CertFile
+	}
+	if b.KeyFile != "" {
+		result.KeyFile = b.KeyFile
+	}
+	return &result
+}
+
+// IncomingTLSConfig returns the TLS configuration used to serve incoming
+// connections.
+func (a *TLSConfig) IncomingTLSConfig() (*tls.Config, error) {
+	return a.tlsutilConfig().IncomingTLSConfig()
+}
+
+// tlsutilConfig converts this config to its tlsutil equivalent.
+func (a *TLSConfig) tlsutilConfig() *tlsutil.Config {
+	return &tlsutil.Config{
+		VerifyIncoming: a.VerifyIncoming,
+		CAFile:         a.CAFile,
+		CertFile:       a.CertFile,
+		KeyFile:        a.KeyFile,
+	}
+}
+
+// Merge merges two meta-data configs together.
+func (a *MetaData) Merge(b *MetaData) *MetaData {
+	result := *a
+
+	if b.InstanceID != "" {
+		result.InstanceID = b.InstanceID
+	}
+	if b.AvailabilityZone != "" {
+		result.AvailabilityZone = b.AvailabilityZone
+	}
+	if b.LocalIPv4 != "" {
+		result.LocalIPv4 = b.LocalIPv4
+	}
+	return &result
+}
+
 // LoadMayaConfig loads the configuration at the given path, regardless if
 // its a file or directory.
 func LoadMayaConfig(path string) (*MayaConfig, error) {
@@ -416,6 +922,8 @@ func LoadMayaConfigDir(dir string) (*MayaConfig, error) {
 				skip = false
 			} else if strings.HasSuffix(name, ".json") {
 				skip = false
+			} else if isYAMLFile(name) {
+				skip = false
 			}
 			if skip || isTemporaryFile(name) {
 				continue
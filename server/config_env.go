@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables that are used to
+// configure Maya server.
+const EnvPrefix = "MAYA_SERVER_"
+
+// envConfigKey maps an environment variable to a config key. Block is
+// empty for the top level keys.
+type envConfigKey struct {
+	Env   string
+	Block string
+	Key   string
+}
+
+// envConfigKeys lists the environment variables understood by Maya server.
+var envConfigKeys = []envConfigKey{
+	{"MAYA_SERVER_REGION", "", "region"},
+	{"MAYA_SERVER_DATACENTER", "", "datacenter"},
+	{"MAYA_SERVER_NAME", "", "name"},
+	{"MAYA_SERVER_DATA_DIR", "", "data_dir"},
+	{"MAYA_SERVER_CLUSTER_ID", "", "cluster_id"},
+	{"MAYA_SERVER_LOG_LEVEL", "", "log_level"},
+	{"MAYA_SERVER_BIND", "", "bind_addr"},
+	{"MAYA_SERVER_ENABLE_DEBUG", "", "enable_debug"},
+	{"MAYA_SERVER_SERVICE_PROVIDER", "", "service_provider"},
+	{"MAYA_SERVER_LEAVE_ON_INTERRUPT", "", "leave_on_interrupt"},
+	{"MAYA_SERVER_LEAVE_ON_TERMINATE", "", "leave_on_terminate"},
+	{"MAYA_SERVER_ENABLE_SYSLOG", "", "enable_syslog"},
+	{"MAYA_SERVER_SYSLOG_FACILITY", "", "syslog_facility"},
+	{"MAYA_SERVER_CRASH_REPORT_WEBHOOK", "", "crash_report_webhook"},
+	{"MAYA_SERVER_FEATURE_GATES", "", "feature_gates"},
+	{"MAYA_SERVER_PORTS_HTTP", "ports", "http"},
+	{"MAYA_SERVER_ADDRESSES_HTTP", "addresses", "http"},
+	{"MAYA_SERVER_ADVERTISE_HTTP", "advertise", "http"},
+	{"MAYA_SERVER_HTTP_SLOW_REQUEST_THRESHOLD", "http", "slow_request_threshold"},
+	{"MAYA_SERVER_HTTP_RATE_LIMIT", "http", "rate_limit"},
+	{"MAYA_SERVER_HTTP_RATE_LIMIT_BURST", "http", "rate_limit_burst"},
+	{"MAYA_SERVER_HTTP_MAX_CONCURRENT_REQUESTS", "http", "max_concurrent_requests"},
+	{"MAYA_SERVER_HTTP_READ_TIMEOUT", "http", "read_timeout"},
+	{"MAYA_SERVER_HTTP_WRITE_TIMEOUT", "http", "write_timeout"},
+	{"MAYA_SERVER_HTTP_IDLE_TIMEOUT", "http", "idle_timeout"},
+	{"MAYA_SERVER_HTTP_MAX_HEADER_BYTES", "http", "max_header_bytes"},
+	{"MAYA_SERVER_TLS_HTTP", "tls", "http"},
+	{"MAYA_SERVER_TLS_VERIFY_INCOMING", "tls", "verify_incoming"},
+	{"MAYA_SERVER_TLS_CA_FILE", "tls", "ca_file"},
+	{"MAYA_SERVER_TLS_CERT_FILE", "tls", "cert_file"},
+	{"MAYA_SERVER_TLS_KEY_FILE", "tls", "key_file"},
+	{"MAYA_SERVER_CORS_ALLOWED_ORIGINS", "cors", "allowed_origins"},
+	{"MAYA_SERVER_CORS_ALLOWED_METHODS", "cors", "allowed_methods"},
+	{"MAYA_SERVER_CORS_ALLOWED_HEADERS", "cors", "allowed_headers"},
+	{"MAYA_SERVER_CORS_MAX_AGE", "cors", "max_age"},
+	{"MAYA_SERVER_META_DATA_INSTANCE_ID", "meta_data", "instance_id"},
+	{"MAYA_SERVER_META_DATA_AVAILABILITY_ZONE", "meta_data", "availability_zone"},
+	{"MAYA_SERVER_META_DATA_LOCAL_IPV4", "meta_data", "local_ipv4"},
+	{"MAYA_SERVER_LOG_SUPPRESSION_ENABLED", "log_suppression", "enabled"},
+	{"MAYA_SERVER_LOG_SUPPRESSION_WINDOW", "log_suppression", "window"},
+}
+
+// envListVars are the environment variables of the list config keys. Their
+// value is a comma separated list, e.g. Snapshots=true,Metrics=false.
+var envListVars = map[string]struct{}{
+	"MAYA_SERVER_FEATURE_GATES":        {},
+	"MAYA_SERVER_CORS_ALLOWED_ORIGINS": {},
+	"MAYA_SERVER_CORS_ALLOWED_METHODS": {},
+	"MAYA_SERVER_CORS_ALLOWED_HEADERS": {},
+}
+
+// envBoolClearers turn off the boolean options whose variable is set to
+// false. Merge can not do it, as it can not tell false from unset.
+var envBoolClearers = map[string]func(mc *MayaConfig){
+	"MAYA_SERVER_ENABLE_DEBUG":       func(mc *MayaConfig) { mc.EnableDebug = false },
+	"MAYA_SERVER_LEAVE_ON_INTERRUPT": func(mc *MayaConfig) { mc.LeaveOnInt = false },
+	"MAYA_SERVER_LEAVE_ON_TERMINATE": func(mc *MayaConfig) { mc.LeaveOnTerm = false },
+	"MAYA_SERVER_ENABLE_SYSLOG":      func(mc *MayaConfig) { mc.EnableSyslog = false },
+	"MAYA_SERVER_TLS_HTTP": func(mc *MayaConfig) {
+		if mc.TLSConfig != nil {
+			tls := *mc.TLSConfig
+			tls.EnableHTTP = false
+			mc.TLSConfig = &tls
+		}
+	},
+	"MAYA_SERVER_TLS_VERIFY_INCOMING": func(mc *MayaConfig) {
+		if mc.TLSConfig != nil {
+			tls := *mc.TLSConfig
+			tls.VerifyIncoming = false
+			mc.TLSConfig = &tls
+		}
+	},
+	"MAYA_SERVER_LOG_SUPPRESSION_ENABLED": func(mc *MayaConfig) {
+		if mc.LogSuppression != nil {
+			ls := *mc.LogSuppression
+			ls.Enabled = false
+			mc.LogSuppression = &ls
+		}
+	},
+}
+
+// envValue returns the value of the given variable as expected by the
+// config parser.
+func envValue(env, value string) interface{} {
+	if _, ok := envListVars[env]; ok {
+		list := splitList([]string{value})
+		if list == nil {
+			list = []string{}
+		}
+		return list
+	}
+	return value
+}
+
+// LoadMayaConfigEnv builds a config out of the MAYA_SERVER_* variables
+// found in the given environment, which is in the format returned by
+// os.Environ. Unknown MAYA_SERVER_* variables are ignored, since e.g.
+// Kubernetes injects MAYA_SERVER_SERVICE_HOST & friends for a service
+// named maya-server.
+//
+// The values go through the same parser as the config files, so they are
+// validated the same way.
+func LoadMayaConfigEnv(environ []string) (*MayaConfig, error) {
+	known := make(map[string]envConfigKey, len(envConfigKeys))
+	for _, k := range envConfigKeys {
+		known[k.Env] = k
+	}
+
+	m := make(map[string]interface{})
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		k, ok := known[parts[0]]
+		if !ok {
+			continue
+		}
+
+		value := envValue(k.Env, parts[1])
+		if k.Block == "" {
+			m[k.Key] = value
+			continue
+		}
+
+		block, ok := m[k.Block].(map[string]interface{})
+		if !ok {
+			block = make(map[string]interface{})
+			m[k.Block] = block
+		}
+		block[k.Key] = value
+	}
+
+	if len(m) == 0 {
+		return &MayaConfig{}, nil
+	}
+
+	js, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMayaConfig(bytes.NewReader(js))
+}
+
+// MergeMayaConfigEnv merges the MAYA_SERVER_* variables found in the given
+// environment over the given config. Unlike a plain Merge of the config
+// returned by LoadMayaConfigEnv, boolean options set to false in the
+// environment are turned off.
+func MergeMayaConfigEnv(mc *MayaConfig, environ []string) (*MayaConfig, error) {
+	envConfig, err := LoadMayaConfigEnv(environ)
+	if err != nil {
+		return nil, err
+	}
+	result := mc.Merge(envConfig)
+
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		clear, ok := envBoolClearers[parts[0]]
+		if !ok {
+			continue
+		}
+
+		// The values have been validated by LoadMayaConfigEnv
+		if enabled, err := strconv.ParseBool(parts[1]); err == nil && !enabled {
+			clear(result)
+		}
+	}
+	return result, nil
+}
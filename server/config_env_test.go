@@ -0,0 +1,146 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadMayaConfigEnv(t *testing.T) {
+	// No MAYA_SERVER_* variables results in an empty config
+	config, err := LoadMayaConfigEnv([]string{"HOME=/root", "PATH=/bin"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(config, &MayaConfig{}) {
+		t.Fatalf("bad: %#v", config)
+	}
+
+	environ := []string{
+		"HOME=/root",
+		"MAYA_SERVER_BIND=0.0.0.0",
+		"MAYA_SERVER_DATA_DIR=/var/lib/maya",
+		"MAYA_SERVER_LOG_LEVEL=DEBUG",
+		"MAYA_SERVER_ENABLE_DEBUG=true",
+		"MAYA_SERVER_PORTS_HTTP=8080",
+		"MAYA_SERVER_TLS_HTTP=true",
+		"MAYA_SERVER_TLS_CERT_FILE=/etc/maya/server.pem",
+		"MAYA_SERVER_META_DATA_INSTANCE_ID=i-1234",
+		"MAYA_SERVER_LOG_SUPPRESSION_WINDOW=1m",
+		"MAYA_SERVER_FEATURE_GATES=Snapshots=true, Metrics=false",
+		// Injected by Kubernetes for a service named maya-server
+		"MAYA_SERVER_SERVICE_HOST=10.0.0.1",
+	}
+	config, err = LoadMayaConfigEnv(environ)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if config.BindAddr != "0.0.0.0" {
+		t.Fatalf("bad bind addr: %q", config.BindAddr)
+	}
+	if config.DataDir != "/var/lib/maya" {
+		t.Fatalf("bad data dir: %q", config.DataDir)
+	}
+	if config.LogLevel != "DEBUG" {
+		t.Fatalf("bad log level: %q", config.LogLevel)
+	}
+	if !config.EnableDebug {
+		t.Fatalf("expected enable debug")
+	}
+	if config.Ports == nil || config.Ports.HTTP != 8080 {
+		t.Fatalf("bad ports: %#v", config.Ports)
+	}
+	if config.TLSConfig == nil || !config.TLSConfig.EnableHTTP ||
+		config.TLSConfig.CertFile != "/etc/maya/server.pem" {
+		t.Fatalf("bad tls: %#v", config.TLSConfig)
+	}
+	if config.MetaData == nil || config.MetaData.InstanceID != "i-1234" {
+		t.Fatalf("bad meta data: %#v", config.MetaData)
+	}
+	if config.LogSuppression == nil || config.LogSuppression.Window != time.Minute {
+		t.Fatalf("bad log suppression: %#v", config.LogSuppression)
+	}
+
+	if !reflect.DeepEqual(config.FeatureGates, []string{"Snapshots=true", "Metrics=false"}) {
+		t.Fatalf("bad feature gates: %#v", config.FeatureGates)
+	}
+	if !config.FeatureEnabled("Snapshots") || config.FeatureEnabled("Metrics") {
+		t.Fatalf("bad features: %#v", config.FeatureGates)
+	}
+
+	// A single feature gate
+	config, err = LoadMayaConfigEnv([]string{"MAYA_SERVER_FEATURE_GATES=Snapshots=true"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(config.FeatureGates, []string{"Snapshots=true"}) {
+		t.Fatalf("bad feature gates: %#v", config.FeatureGates)
+	}
+
+	// CORS lists
+	config, err = LoadMayaConfigEnv([]string{
+		"MAYA_SERVER_CORS_ALLOWED_ORIGINS=https://dashboard.openebs.io",
+		"MAYA_SERVER_CORS_ALLOWED_METHODS=GET,POST",
+		"MAYA_SERVER_CORS_ALLOWED_HEADERS=Content-Type, X-Request-ID",
+		"MAYA_SERVER_CORS_MAX_AGE=10m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := &CORSConfig{
+		AllowedOrigins: []string{"https://dashboard.openebs.io"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+	if !reflect.DeepEqual(config.CORS, expected) {
+		t.Fatalf("bad cors: %#v", config.CORS)
+	}
+
+	// Invalid values are reported
+	if _, err := LoadMayaConfigEnv([]string{"MAYA_SERVER_PORTS_HTTP=abc"}); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
+
+func TestMergeMayaConfigEnv(t *testing.T) {
+	conf := DefaultMayaConfig()
+	conf.EnableSyslog = true
+	conf.LeaveOnInt = true
+	conf.TLSConfig.EnableHTTP = true
+	conf.TLSConfig.VerifyIncoming = true
+	conf.LogSuppression.Enabled = true
+
+	environ := []string{
+		"MAYA_SERVER_ENABLE_SYSLOG=false",
+		"MAYA_SERVER_TLS_HTTP=0",
+		"MAYA_SERVER_LOG_SUPPRESSION_ENABLED=false",
+		"MAYA_SERVER_TLS_VERIFY_INCOMING=true",
+		"MAYA_SERVER_ENABLE_DEBUG=true",
+	}
+	merged, err := MergeMayaConfigEnv(conf, environ)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Options set to false in the environment are turned off
+	if merged.EnableSyslog || merged.TLSConfig.EnableHTTP || merged.LogSuppression.Enabled {
+		t.Fatalf("bad: %#v", merged)
+	}
+
+	// Others are left alone or turned on
+	if !merged.LeaveOnInt || !merged.TLSConfig.VerifyIncoming || !merged.EnableDebug {
+		t.Fatalf("bad: %#v", merged)
+	}
+
+	// The merged config is left untouched
+	if !conf.EnableSyslog || !conf.TLSConfig.EnableHTTP || !conf.LogSuppression.Enabled {
+		t.Fatalf("expected the original config to be left untouched: %#v", conf)
+	}
+
+	// Invalid values are reported
+	if _, err := MergeMayaConfigEnv(conf, []string{"MAYA_SERVER_ENABLE_SYSLOG=maybe"}); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
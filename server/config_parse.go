@@ -2,15 +2,18 @@ package server
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
 	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v2"
 )
 
 // ParseMayaConfigFile parses the given path as a config file.
@@ -26,7 +29,12 @@ func ParseMayaConfigFile(path string) (*MayaConfig, error) {
 	}
 	defer f.Close()
 
-	mconfig, err := ParseMayaConfig(f)
+	var mconfig *MayaConfig
+	if isYAMLFile(path) {
+		mconfig, err = ParseMayaConfigYAML(f)
+	} else {
+		mconfig, err = ParseMayaConfig(f)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +42,82 @@ func ParseMayaConfigFile(path string) (*MayaConfig, error) {
 	return mconfig, nil
 }
 
+// ParseMayaConfigYAML parses a YAML config from the given io.Reader.
+//
+// The YAML document is converted to JSON and then handed over to
+// ParseMayaConfig, so YAML files accept exactly the same keys & blocks
+// as their HCL & JSON counterparts.
+func ParseMayaConfigYAML(r io.Reader) (*MayaConfig, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &raw); err != nil {
+		return nil, fmt.Errorf("error parsing: %s", err)
+	}
+
+	// An empty document is a valid (empty) config
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+
+	converted, err := convertYAMLValue(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing: %s", err)
+	}
+	if _, ok := converted.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("error parsing: root should be an object")
+	}
+
+	js, err := json.Marshal(converted)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing: %s", err)
+	}
+
+	return ParseMayaConfig(bytes.NewReader(js))
+}
+
+// convertYAMLValue converts the map[interface{}]interface{} values produced
+// by the YAML decoder into map[string]interface{} so they can be encoded
+// as JSON.
+func convertYAMLValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid key: %v", k)
+			}
+			cv, err := convertYAMLValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = cv
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, val := range t {
+			cv, err := convertYAMLValue(val)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = cv
+		}
+		return l, nil
+	default:
+		return v, nil
+	}
+}
+
+// isYAMLFile returns true if the given path has a YAML extension.
+func isYAMLFile(path string) bool {
+	return strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+}
+
 // ParseMayaConfig parses the config from the given io.Reader.
 //
 // Due to current internal limitations, the entire contents of the
@@ -76,14 +160,23 @@ func parseConfig(result *MayaConfig, list *ast.ObjectList) error {
 		"log_level",
 		"bind_addr",
 		"enable_debug",
+		"service_provider",
 		"ports",
 		"addresses",
 		"interfaces",
 		"advertise",
+		"http",
+		"tls",
+		"cors",
+		"meta_data",
 		"leave_on_interrupt",
 		"leave_on_terminate",
+		"log_suppression",
 		"enable_syslog",
 		"syslog_facility",
+		"crash_report_webhook",
+		"cluster_id",
+		"feature_gates",
 		"http_api_response_headers",
 	}
 	if err := checkHCLKeys(list, valid); err != nil {
@@ -99,6 +192,11 @@ func parseConfig(result *MayaConfig, list *ast.ObjectList) error {
 	delete(m, "addresses")
 	delete(m, "interfaces")
 	delete(m, "advertise")
+	delete(m, "log_suppression")
+	delete(m, "http")
+	delete(m, "tls")
+	delete(m, "cors")
+	delete(m, "meta_data")
 	delete(m, "http_api_response_headers")
 
 	// Decode the rest
@@ -127,6 +225,41 @@ func parseConfig(result *MayaConfig, list *ast.ObjectList) error {
 		}
 	}
 
+	// Parse log_suppression
+	if o := list.Filter("log_suppression"); len(o.Items) > 0 {
+		if err := parseLogSuppression(&result.LogSuppression, o); err != nil {
+			return multierror.Prefix(err, "log_suppression ->")
+		}
+	}
+
+	// Parse http
+	if o := list.Filter("http"); len(o.Items) > 0 {
+		if err := parseHTTPConfig(&result.HTTP, o); err != nil {
+			return multierror.Prefix(err, "http ->")
+		}
+	}
+
+	// Parse tls
+	if o := list.Filter("tls"); len(o.Items) > 0 {
+		if err := parseTLSConfig(&result.TLSConfig, o); err != nil {
+			return multierror.Prefix(err, "tls ->")
+		}
+	}
+
+	// Parse cors
+	if o := list.Filter("cors"); len(o.Items) > 0 {
+		if err := parseCORSConfig(&result.CORS, o); err != nil {
+			return multierror.Prefix(err, "cors ->")
+		}
+	}
+
+	// Parse meta_data
+	if o := list.Filter("meta_data"); len(o.Items) > 0 {
+		if err := parseMetaData(&result.MetaData, o); err != nil {
+			return multierror.Prefix(err, "meta_data ->")
+		}
+	}
+
 	// Parse the nomad config
 	//if o := list.Filter("nomad"); len(o.Items) > 0 {
 	//	if err := parseNomadConfig(&result.Nomad, o); err != nil {
@@ -241,6 +374,223 @@ func parseAdvertise(result **AdvertiseAddrs, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseLogSuppression(result **LogSuppression, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'log_suppression' block allowed")
+	}
+
+	// Get our log_suppression object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"enabled",
+		"window",
+		"subsystems",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+	delete(m, "subsystems")
+
+	var logSuppression LogSuppression
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &logSuppression,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+
+	// Parse out the subsystems' windows. These are in HCL as a list so
+	// we need to iterate over them and merge them.
+	if o, ok := listVal.(*ast.ObjectType); ok {
+		for _, so := range o.List.Filter("subsystems").Elem().Items {
+			var sm map[string]interface{}
+			if err := hcl.DecodeObject(&sm, so.Val); err != nil {
+				return err
+			}
+
+			dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+				WeaklyTypedInput: true,
+				Result:           &logSuppression.Subsystems,
+			})
+			if err != nil {
+				return err
+			}
+			if err := dec.Decode(sm); err != nil {
+				return multierror.Prefix(err, "subsystems ->")
+			}
+		}
+	}
+
+	*result = &logSuppression
+	return nil
+}
+
+func parseHTTPConfig(result **HTTPConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'http' block allowed")
+	}
+
+	// Get our http object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"slow_request_threshold",
+		"rate_limit",
+		"rate_limit_burst",
+		"max_concurrent_requests",
+		"read_timeout",
+		"write_timeout",
+		"idle_timeout",
+		"max_header_bytes",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var httpConfig HTTPConfig
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &httpConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+	*result = &httpConfig
+	return nil
+}
+
+func parseTLSConfig(result **TLSConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'tls' block allowed")
+	}
+
+	// Get our tls object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"http",
+		"verify_incoming",
+		"ca_file",
+		"cert_file",
+		"key_file",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var tlsConfig TLSConfig
+	if err := mapstructure.WeakDecode(m, &tlsConfig); err != nil {
+		return err
+	}
+	*result = &tlsConfig
+	return nil
+}
+
+func parseCORSConfig(result **CORSConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'cors' block allowed")
+	}
+
+	// Get our cors object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"allowed_origins",
+		"allowed_methods",
+		"allowed_headers",
+		"max_age",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var corsConfig CORSConfig
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &corsConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+	*result = &corsConfig
+	return nil
+}
+
+func parseMetaData(result **MetaData, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'meta_data' block allowed")
+	}
+
+	// Get our meta_data object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"instance_id",
+		"availability_zone",
+		"local_ipv4",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var metaData MetaData
+	if err := mapstructure.WeakDecode(m, &metaData); err != nil {
+		return err
+	}
+	*result = &metaData
+	return nil
+}
+
 func checkHCLKeys(node ast.Node, valid []string) error {
 	var list *ast.ObjectList
 	switch n := node.(type) {
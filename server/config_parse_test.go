@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestMayaConfig_Parse(t *testing.T) {
@@ -15,13 +16,15 @@ func TestMayaConfig_Parse(t *testing.T) {
 		{
 			"dummy_mayaserver_config.hcl",
 			&MayaConfig{
-				Region:      "BANG-EAST",
-				Datacenter:  "dc2",
-				NodeName:    "my-vsm",
-				DataDir:     "/tmp/mayaserver",
-				LogLevel:    "ERR",
-				BindAddr:    "192.168.0.1",
-				EnableDebug: true,
+				Region:          "BANG-EAST",
+				Datacenter:      "dc2",
+				NodeName:        "my-vsm",
+				DataDir:         "/tmp/mayaserver",
+				ClusterID:       "maya-prod",
+				LogLevel:        "ERR",
+				BindAddr:        "192.168.0.1",
+				EnableDebug:     true,
+				ServiceProvider: "nomad",
 				Ports: &Ports{
 					HTTP: 1234,
 				},
@@ -29,10 +32,48 @@ func TestMayaConfig_Parse(t *testing.T) {
 					HTTP: "127.0.0.1",
 				},
 				AdvertiseAddrs: &AdvertiseAddrs{},
-				LeaveOnInt:     true,
-				LeaveOnTerm:    true,
-				EnableSyslog:   true,
-				SyslogFacility: "LOCAL1",
+				HTTP: &HTTPConfig{
+					SlowRequestThreshold:  2 * time.Second,
+					RateLimit:             2.5,
+					RateLimitBurst:        10,
+					MaxConcurrentRequests: 64,
+					ReadTimeout:           10 * time.Second,
+					WriteTimeout:          20 * time.Second,
+					IdleTimeout:           time.Minute,
+					MaxHeaderBytes:        65536,
+				},
+				TLSConfig: &TLSConfig{
+					EnableHTTP:     true,
+					VerifyIncoming: true,
+					CAFile:         "/etc/mayaserver/ca.pem",
+					CertFile:       "/etc/mayaserver/server.pem",
+					KeyFile:        "/etc/mayaserver/server-key.pem",
+				},
+				CORS: &CORSConfig{
+					AllowedOrigins: []string{"https://dashboard.openebs.io"},
+					AllowedMethods: []string{"GET", "POST"},
+					AllowedHeaders: []string{"Content-Type"},
+					MaxAge:         10 * time.Minute,
+				},
+				MetaData: &MetaData{
+					InstanceID:       "i-maya01",
+					AvailabilityZone: "bang-east-1a",
+					LocalIPv4:        "192.168.0.1",
+				},
+				LeaveOnInt:  true,
+				LeaveOnTerm: true,
+				LogSuppression: &LogSuppression{
+					Enabled: true,
+					Window:  30 * time.Second,
+					Subsystems: map[string]time.Duration{
+						"http":       time.Minute,
+						"mayaserver": 0,
+					},
+				},
+				EnableSyslog:       true,
+				SyslogFacility:     "LOCAL1",
+				CrashReportWebhook: "https://crash.example.com/mayaserver",
+				FeatureGates:       []string{"Snapshots=true", "Raft=false"},
 				HTTPAPIResponseHeaders: map[string]string{
 					"Access-Control-Allow-Origin": "*",
 				},
@@ -7,6 +7,9 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
 )
 
 var (
@@ -35,23 +38,43 @@ func TestMayaConfig_Merge(t *testing.T) {
 			HTTP: "127.0.0.1",
 		},
 		AdvertiseAddrs: &AdvertiseAddrs{},
+		HTTP: &HTTPConfig{
+			SlowRequestThreshold: time.Second,
+		},
+		TLSConfig: &TLSConfig{
+			CAFile: "/tmp/ca1.pem",
+		},
+		MetaData: &MetaData{
+			InstanceID: "i-one",
+		},
 		HTTPAPIResponseHeaders: map[string]string{
 			"Access-Control-Allow-Origin": "*",
 		},
 	}
 
 	c2 := &MayaConfig{
-		Region:         "region2",
-		Datacenter:     "dc2",
-		NodeName:       "node2",
-		DataDir:        "/tmp/dir2",
-		LogLevel:       "DEBUG",
-		EnableDebug:    true,
-		LeaveOnInt:     true,
-		LeaveOnTerm:    true,
-		EnableSyslog:   true,
-		SyslogFacility: "local0.debug",
-		BindAddr:       "127.0.0.2",
+		Region:          "region2",
+		Datacenter:      "dc2",
+		NodeName:        "node2",
+		DataDir:         "/tmp/dir2",
+		ClusterID:       "maya-prod",
+		LogLevel:        "DEBUG",
+		EnableDebug:     true,
+		ServiceProvider: "nomad",
+		LeaveOnInt:      true,
+		LeaveOnTerm:     true,
+		LogSuppression: &LogSuppression{
+			Enabled: true,
+			Window:  time.Minute,
+			Subsystems: map[string]time.Duration{
+				"http": 0,
+			},
+		},
+		EnableSyslog:       true,
+		SyslogFacility:     "local0.debug",
+		CrashReportWebhook: "https://crash.example.com/mayaserver",
+		FeatureGates:       []string{"Snapshots=true", "Raft=false"},
+		BindAddr:           "127.0.0.2",
 		Ports: &Ports{
 			HTTP: 20000,
 		},
@@ -59,6 +82,34 @@ func TestMayaConfig_Merge(t *testing.T) {
 			HTTP: "127.0.0.2",
 		},
 		AdvertiseAddrs: &AdvertiseAddrs{},
+		HTTP: &HTTPConfig{
+			SlowRequestThreshold:  5 * time.Second,
+			RateLimit:             100,
+			RateLimitBurst:        200,
+			MaxConcurrentRequests: 32,
+			ReadTimeout:           5 * time.Second,
+			WriteTimeout:          10 * time.Second,
+			IdleTimeout:           time.Minute,
+			MaxHeaderBytes:        4096,
+		},
+		TLSConfig: &TLSConfig{
+			EnableHTTP:     true,
+			VerifyIncoming: true,
+			CAFile:         "/tmp/ca2.pem",
+			CertFile:       "/tmp/cert2.pem",
+			KeyFile:        "/tmp/key2.pem",
+		},
+		CORS: &CORSConfig{
+			AllowedOrigins: []string{"https://dashboard.openebs.io"},
+			AllowedMethods: []string{"GET"},
+			AllowedHeaders: []string{"Content-Type"},
+			MaxAge:         time.Hour,
+		},
+		MetaData: &MetaData{
+			InstanceID:       "i-two",
+			AvailabilityZone: "zone-two",
+			LocalIPv4:        "127.0.0.2",
+		},
 		HTTPAPIResponseHeaders: map[string]string{
 			"Access-Control-Allow-Origin":  "*",
 			"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
@@ -144,6 +195,12 @@ func TestConfig_LoadMayaConfigDir(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
+	file4 := filepath.Join(dir, "conf4.yaml")
+	err = ioutil.WriteFile(file4, []byte("name: node1\nports:\n  http: 8080\n"), 0600)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
 	file3 := filepath.Join(dir, "conf3.hcl")
 	err = ioutil.WriteFile(file3, []byte(`nope;!!!`), 0600)
 	if err != nil {
@@ -167,6 +224,72 @@ func TestConfig_LoadMayaConfigDir(t *testing.T) {
 	if config.Region != "west" || config.Datacenter != "sfo" {
 		t.Fatalf("bad: %#v", config)
 	}
+	if config.NodeName != "node1" || config.Ports.HTTP != 8080 {
+		t.Fatalf("bad: %#v", config)
+	}
+}
+
+func TestConfig_ParseMayaConfigFile_YAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maya")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Invalid content returns error
+	bad := filepath.Join(dir, "bad.yml")
+	if err := ioutil.WriteFile(bad, []byte("region: [west"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := ParseMayaConfigFile(bad); err == nil {
+		t.Fatalf("expected load error, got nothing")
+	}
+
+	// Unknown keys are rejected just like in HCL
+	unknown := filepath.Join(dir, "unknown.yaml")
+	if err := ioutil.WriteFile(unknown, []byte("unicorns: true\n"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := ParseMayaConfigFile(unknown); err == nil {
+		t.Fatalf("expected load error, got nothing")
+	}
+
+	// Valid content parses successfully
+	good := filepath.Join(dir, "good.yaml")
+	content := `
+region: west
+enable_debug: true
+ports:
+  http: 8080
+log_suppression:
+  enabled: true
+  window: 30s
+  subsystems:
+    http: 1m
+http_api_response_headers:
+  Access-Control-Allow-Origin: "*"
+`
+	if err := ioutil.WriteFile(good, []byte(content), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config, err := ParseMayaConfigFile(good)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if config.Region != "west" || !config.EnableDebug {
+		t.Fatalf("bad: %#v", config)
+	}
+	if config.Ports.HTTP != 8080 {
+		t.Fatalf("bad ports: %#v", config.Ports)
+	}
+	if ls := config.LogSuppression; !ls.Enabled || ls.Window != 30*time.Second ||
+		ls.Subsystems["http"] != time.Minute {
+		t.Fatalf("bad log_suppression: %#v", ls)
+	}
+	if v := config.HTTPAPIResponseHeaders["Access-Control-Allow-Origin"]; v != "*" {
+		t.Fatalf("bad headers: %#v", config.HTTPAPIResponseHeaders)
+	}
 }
 
 func TestConfig_LoadMayaConfig(t *testing.T) {
@@ -308,3 +431,98 @@ func TestIsMissingPort(t *testing.T) {
 		t.Errorf("expected no error, but got %v", err)
 	}
 }
+
+func TestMayaConfig_Validate(t *testing.T) {
+	// The defaults are valid
+	if err := DefaultMayaConfig().Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		Name   string
+		Modify func(mc *MayaConfig)
+	}{
+		{"port", func(mc *MayaConfig) { mc.Ports.HTTP = 0 }},
+		{"data dir", func(mc *MayaConfig) { mc.DataDir = "tmp" }},
+		{"log level", func(mc *MayaConfig) { mc.LogLevel = "chatty" }},
+		{"service provider", func(mc *MayaConfig) { mc.ServiceProvider = "unicorns" }},
+		{"cors origin", func(mc *MayaConfig) { mc.CORS.AllowedOrigins = []string{"dashboard"} }},
+		{"cors max age", func(mc *MayaConfig) { mc.CORS.MaxAge = -time.Second }},
+		{"cluster id", func(mc *MayaConfig) { mc.ClusterID = "maya prod" }},
+		{"crash report webhook", func(mc *MayaConfig) { mc.CrashReportWebhook = "ftp://example.com" }},
+		{"feature gates", func(mc *MayaConfig) { mc.FeatureGates = []string{"Snapshots"} }},
+		{"slow request threshold", func(mc *MayaConfig) { mc.HTTP.SlowRequestThreshold = -time.Second }},
+		{"rate limit", func(mc *MayaConfig) { mc.HTTP.RateLimit = -1 }},
+		{"rate limit burst", func(mc *MayaConfig) { mc.HTTP.RateLimitBurst = -1 }},
+		{"max concurrent requests", func(mc *MayaConfig) { mc.HTTP.MaxConcurrentRequests = -1 }},
+		{"read timeout", func(mc *MayaConfig) { mc.HTTP.ReadTimeout = -time.Second }},
+		{"write timeout", func(mc *MayaConfig) { mc.HTTP.WriteTimeout = -time.Second }},
+		{"idle timeout", func(mc *MayaConfig) { mc.HTTP.IdleTimeout = -time.Second }},
+		{"max header bytes", func(mc *MayaConfig) { mc.HTTP.MaxHeaderBytes = -1 }},
+		{"tls key pair", func(mc *MayaConfig) { mc.TLSConfig.EnableHTTP = true }},
+		{"tls ca", func(mc *MayaConfig) {
+			mc.TLSConfig = &TLSConfig{
+				EnableHTTP:     true,
+				VerifyIncoming: true,
+				CertFile:       "/tmp/cert.pem",
+				KeyFile:        "/tmp/key.pem",
+			}
+		}},
+	}
+
+	for _, tc := range cases {
+		conf := DefaultMayaConfig()
+		tc.Modify(conf)
+		if err := conf.Validate(); err == nil {
+			t.Fatalf("%s: expected error, got nothing", tc.Name)
+		}
+	}
+
+	// All the problems are reported
+	conf := DefaultMayaConfig()
+	for _, tc := range cases {
+		tc.Modify(conf)
+	}
+	err := conf.Validate()
+	if merr, ok := err.(*multierror.Error); !ok || len(merr.Errors) != len(cases)-1 {
+		t.Fatalf("expected %d errors, got: %v", len(cases)-1, err)
+	}
+}
+
+func TestMayaConfig_Features(t *testing.T) {
+	conf := &MayaConfig{
+		FeatureGates: []string{"Snapshots=true", "Raft=false, Metrics=true", "Raft=true"},
+	}
+	features, err := conf.Features()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := map[string]bool{
+		"Snapshots": true,
+		"Metrics":   true,
+		"Raft":      true,
+	}
+	if !reflect.DeepEqual(features, expected) {
+		t.Fatalf("bad: %#v", features)
+	}
+	if !conf.FeatureEnabled("Snapshots") || conf.FeatureEnabled("Unknown") {
+		t.Fatalf("bad feature enabled: %#v", features)
+	}
+
+	// Merged gates are appended, so the later ones win
+	merged := conf.Merge(&MayaConfig{FeatureGates: []string{"Snapshots=false"}})
+	if merged.FeatureEnabled("Snapshots") || !merged.FeatureEnabled("Raft") {
+		t.Fatalf("bad merged gates: %#v", merged.FeatureGates)
+	}
+
+	for _, gate := range []string{"Snapshots", "=true", "Snapshots=maybe"} {
+		conf := &MayaConfig{FeatureGates: []string{gate}}
+		if _, err := conf.Features(); err == nil {
+			t.Fatalf("gate: %q, expected error, got nothing", gate)
+		}
+		if conf.FeatureEnabled("Snapshots") {
+			t.Fatalf("gate: %q, expected invalid gate to be turned off", gate)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	// crashReportTimeout bounds the time spent posting a crash report to
+	// the webhook, as the process is about to exit.
+	crashReportTimeout = 10 * time.Second
+
+	// recoveredReportInterval is the least time between the reports of
+	// recovered panics, e.g. of HTTP handlers, which may repeat on every
+	// request.
+	recoveredReportInterval = time.Minute
+
+	// maxCrashReports is the number of crash reports kept in a directory.
+	// Older reports are removed.
+	maxCrashReports = 10
+)
+
+// CrashReport holds what is needed to diagnose a crash of Maya server.
+type CrashReport struct {
+	Time         time.Time `json:"time"`
+	Version      string    `json:"version"`
+	Revision     string    `json:"revision"`
+	NodeName     string    `json:"node_name"`
+	Panic        string    `json:"panic"`
+	Stack        string    `json:"stack"`
+	RecentLogs   []string  `json:"recent_logs"`
+	ConfigDigest string    `json:"config_digest"`
+}
+
+// CrashReporter reports the panics of Maya server, be it on the main
+// goroutine, in an HTTP handler or in a background goroutine. A nil
+// CrashReporter reports nothing.
+type CrashReporter struct {
+	config    *MayaConfig
+	logWriter *LogWriter
+	logger    *log.Logger
+
+	lock          sync.Mutex
+	lastRecovered time.Time
+}
+
+// NewCrashReporter returns a CrashReporter for the given config. The
+// recent logs held by logWriter are added to the reports; logWriter may be
+// nil. Failures to report a crash are logged to logOutput.
+func NewCrashReporter(mconfig *MayaConfig, logWriter *LogWriter, logOutput io.Writer) *CrashReporter {
+	return &CrashReporter{
+		config:    mconfig,
+		logWriter: logWriter,
+		logger:    log.New(logOutput, "", log.LstdFlags|log.Lmicroseconds),
+	}
+}
+
+// Report writes the crash report of the given panic to the data_dir, &
+// posts it to the crash report webhook if one is set. It returns the path
+// of the report, which is empty if it was not written.
+func (c *CrashReporter) Report(r interface{}, stack []byte) string {
+	if c == nil {
+		return ""
+	}
+
+	report := c.newReport(r, stack)
+	path := c.write(report)
+	c.post(report)
+	return path
+}
+
+// ReportRecovered reports a panic that was recovered, e.g. of an HTTP
+// handler, while Maya server keeps running. As such panics may repeat, at
+// most one is reported per recoveredReportInterval & the report is posted
+// to the webhook in the background. It returns the path of the report,
+// which is empty if it was not written.
+func (c *CrashReporter) ReportRecovered(r interface{}, stack []byte) string {
+	if c == nil {
+		return ""
+	}
+
+	c.lock.Lock()
+	now := time.Now()
+	if !c.lastRecovered.IsZero() && now.Sub(c.lastRecovered) < recoveredReportInterval {
+		c.lock.Unlock()
+		return ""
+	}
+	c.lastRecovered = now
+	c.lock.Unlock()
+
+	report := c.newReport(r, stack)
+	path := c.write(report)
+	go c.post(report)
+	return path
+}
+
+// newReport builds the crash report of the given panic.
+func (c *CrashReporter) newReport(r interface{}, stack []byte) *CrashReport {
+	var logs []string
+	if c.logWriter != nil {
+		logs = c.logWriter.Logs()
+	}
+	return NewCrashReport(c.config, r, stack, logs)
+}
+
+// write writes the report to the data_dir & returns its path, which is
+// empty if it could not be written.
+func (c *CrashReporter) write(report *CrashReport) string {
+	path, err := report.Write(c.config.DataDir)
+	if err != nil {
+		c.logger.Printf("[ERR] mayaserver: Error writing crash report: %v", err)
+	}
+	return path
+}
+
+// post posts the report to the crash report webhook, if one is set.
+func (c *CrashReporter) post(report *CrashReport) {
+	if c.config.CrashReportWebhook == "" {
+		return
+	}
+	if err := report.Post(c.config.CrashReportWebhook); err != nil {
+		c.logger.Printf("[ERR] mayaserver: Error posting crash report: %v", err)
+	}
+}
+
+// Go runs f in a new goroutine. A panic of f is reported, then resumed so
+// that the process crashes as it would otherwise.
+func (c *CrashReporter) Go(f func()) {
+	go func() {
+		defer c.reportPanic()
+		f()
+	}()
+}
+
+// reportPanic reports & resumes a panic. It must be deferred directly so
+// that it can recover the panic.
+func (c *CrashReporter) reportPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if path := c.Report(r, debug.Stack()); path != "" {
+		c.logger.Printf("[ERR] mayaserver: Crashed: %v, crash report written to %s", r, path)
+	}
+	panic(r)
+}
+
+// NewCrashReport builds the crash report of the given panic. The logs are
+// the last lines logged before the crash & may be nil.
+func NewCrashReport(mconfig *MayaConfig, r interface{}, stack []byte, logs []string) *CrashReport {
+	return &CrashReport{
+		Time:         time.Now().UTC(),
+		Version:      fmt.Sprintf("%s%s", mconfig.Version, mconfig.VersionPrerelease),
+		Revision:     mconfig.Revision,
+		NodeName:     mconfig.NodeName,
+		Panic:        fmt.Sprintf("%v", r),
+		Stack:        string(stack),
+		RecentLogs:   logs,
+		ConfigDigest: configDigest(mconfig),
+	}
+}
+
+// configDigest returns a SHA256 digest of the config. This tells whether
+// the crashed server ran with a given config, without disclosing it.
+func configDigest(mconfig *MayaConfig) string {
+	buf, err := json.Marshal(mconfig)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// Write writes the crash report as a JSON file in the given directory, or
+// in the temp directory if dir is empty, & returns the path of the file.
+// Only the latest maxCrashReports reports are kept in the directory.
+func (c *CrashReport) Write(dir string) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	buf, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return "", err
+	}
+
+	// The random suffix keeps reports of the same millisecond apart
+	f, err := ioutil.TempFile(dir, fmt.Sprintf("crash-%s-*.json", c.Time.Format("20060102T150405.000Z")))
+	if err != nil {
+		return "", fmt.Errorf("failed to write crash report: %v", err)
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write crash report: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write crash report: %v", err)
+	}
+
+	pruneCrashReports(dir, maxCrashReports)
+	return f.Name(), nil
+}
+
+// pruneCrashReports removes the oldest crash reports of the given
+// directory, so that at most max of them are kept. The names of the
+// reports start with their time, so they sort from oldest to latest.
+func pruneCrashReports(dir string, max int) {
+	paths, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if err != nil || len(paths) <= max {
+		return
+	}
+	sort.Strings(paths)
+	for _, path := range paths[:len(paths)-max] {
+		os.Remove(path)
+	}
+}
+
+// Post sends the crash report as JSON to the given webhook URL.
+func (c *CrashReport) Post(url string) error {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	client := cleanhttp.DefaultClient()
+	client.Timeout = crashReportTimeout
+	resp, err := client.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("failed to post crash report: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post crash report: unexpected status %s", resp.Status)
+	}
+	return nil
+}
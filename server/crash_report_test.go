@@ -0,0 +1,284 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrashReport_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := DefaultMayaConfig()
+	conf.Version = "0.2.0"
+	conf.VersionPrerelease = "-dev"
+	report := NewCrashReport(conf, "boom", []byte("goroutine 1 [running]:"), []string{"one", "two"})
+
+	if report.Version != "0.2.0-dev" || report.Panic != "boom" {
+		t.Fatalf("bad: %#v", report)
+	}
+	if report.ConfigDigest == "" || report.ConfigDigest != configDigest(conf) {
+		t.Fatalf("bad config digest: %q", report.ConfigDigest)
+	}
+	if report.ConfigDigest == configDigest(DefaultMayaConfig()) {
+		t.Fatalf("expected config digest to change with the config")
+	}
+
+	path, err := report.Write(dir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "crash-") {
+		t.Fatalf("bad path: %s", path)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out CrashReport
+	if err := json.Unmarshal(buf, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Panic != "boom" || out.Stack != "goroutine 1 [running]:" ||
+		!reflect.DeepEqual(out.RecentLogs, []string{"one", "two"}) {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestCrashReport_Post(t *testing.T) {
+	var received CrashReport
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/crash" {
+			resp.WriteHeader(404)
+			return
+		}
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			resp.WriteHeader(400)
+		}
+	}))
+	defer ts.Close()
+
+	report := NewCrashReport(DefaultMayaConfig(), "boom", nil, nil)
+	if err := report.Post(ts.URL + "/crash"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if received.Panic != "boom" {
+		t.Fatalf("bad: %#v", received)
+	}
+
+	// Errors are reported
+	if err := report.Post(ts.URL + "/unknown"); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
+
+func TestCrashReporter_Report(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var posted CrashReport
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&posted)
+	}))
+	defer ts.Close()
+
+	conf := DefaultMayaConfig()
+	conf.DataDir = dir
+	conf.CrashReportWebhook = ts.URL
+	logWriter := NewLogWriter(16)
+	logWriter.Write([]byte("[INFO] mayaserver: about to crash"))
+
+	var buf bytes.Buffer
+	c := NewCrashReporter(conf, logWriter, &buf)
+	path := c.Report("boom", []byte("goroutine 1 [running]:"))
+	if filepath.Dir(path) != dir {
+		t.Fatalf("bad path: %s", path)
+	}
+	if posted.Panic != "boom" || !reflect.DeepEqual(posted.RecentLogs, []string{"[INFO] mayaserver: about to crash"}) {
+		t.Fatalf("bad: %#v", posted)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no errors, got: %s", buf.String())
+	}
+
+	// A nil CrashReporter reports nothing
+	var nilReporter *CrashReporter
+	if path := nilReporter.Report("boom", nil); path != "" {
+		t.Fatalf("expected no report, got: %s", path)
+	}
+}
+
+func TestCrashReporter_ReportPanic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := DefaultMayaConfig()
+	conf.DataDir = dir
+	var buf bytes.Buffer
+	c := NewCrashReporter(conf, nil, &buf)
+
+	// The panic is resumed once reported
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be resumed, got: %v", r)
+			}
+		}()
+		defer c.reportPanic()
+		panic("boom")
+	}()
+
+	files, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a crash report, got: %v, err: %v", files, err)
+	}
+	if !strings.Contains(buf.String(), "Crashed: boom, crash report written to "+files[0]) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestHTTPServer_PanicReport(t *testing.T) {
+	unblock := make(chan struct{})
+	posted := make(chan struct{}, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		posted <- struct{}{}
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.CrashReportWebhook = ts.URL
+		mc.HTTP.MaxConcurrentRequests = 1
+	})
+	defer s.Cleanup()
+	s.Maya.SetCrashReporter(NewCrashReporter(s.Maya.config, nil, ioutil.Discard))
+
+	panicking := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		panic("boom")
+	}
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return "ok", nil
+	}
+
+	// The request does not wait for the webhook, which blocks
+	req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+	for i := 0; i < 2; i++ {
+		resp := httptest.NewRecorder()
+		s.Server.wrap(panicking)(resp, req)
+		if resp.Code != 500 {
+			t.Fatalf("expected 500, got: %d", resp.Code)
+		}
+	}
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the crash report to be posted")
+	}
+
+	// The concurrency slot is not held while posting
+	resp := httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got: %d", resp.Code)
+	}
+
+	// Repeated panics are reported once
+	files, err := filepath.Glob(filepath.Join(s.Dir, "crash-*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a crash report, got: %v, err: %v", files, err)
+	}
+	select {
+	case <-posted:
+		t.Fatalf("expected a single crash report to be posted")
+	default:
+	}
+}
+
+func TestCrashReport_WritePrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Reports of the same millisecond do not overwrite each other
+	report := NewCrashReport(DefaultMayaConfig(), "boom", nil, nil)
+	seen := make(map[string]struct{})
+	for i := 0; i < maxCrashReports+5; i++ {
+		path, err := report.Write(dir)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		seen[path] = struct{}{}
+	}
+	if len(seen) != maxCrashReports+5 {
+		t.Fatalf("expected distinct paths, got: %v", seen)
+	}
+
+	// Only the latest reports are kept
+	files, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if err != nil || len(files) != maxCrashReports {
+		t.Fatalf("expected %d crash reports, got: %v, err: %v", maxCrashReports, files, err)
+	}
+
+	later := NewCrashReport(DefaultMayaConfig(), "boom", nil, nil)
+	later.Time = later.Time.Add(time.Hour)
+	path, err := later.Write(dir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the latest report to be kept: %v", err)
+	}
+}
+
+func TestCrashReporter_ReportRecovered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mayaserver")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := DefaultMayaConfig()
+	conf.DataDir = dir
+	c := NewCrashReporter(conf, nil, ioutil.Discard)
+
+	if path := c.ReportRecovered("boom", nil); path == "" {
+		t.Fatalf("expected a crash report")
+	}
+	if path := c.ReportRecovered("boom", nil); path != "" {
+		t.Fatalf("expected no crash report within the interval, got: %s", path)
+	}
+
+	// Reported again once the interval elapsed
+	c.lastRecovered = time.Now().Add(-recoveredReportInterval)
+	if path := c.ReportRecovered("boom", nil); path == "" {
+		t.Fatalf("expected a crash report")
+	}
+
+	// A nil CrashReporter reports nothing
+	var nilReporter *CrashReporter
+	if path := nilReporter.ReportRecovered("boom", nil); path != "" {
+		t.Fatalf("expected no report, got: %s", path)
+	}
+}
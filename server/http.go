@@ -3,26 +3,49 @@ package server
 // This is an adaptation of Hashicorp's Nomad library.
 import (
 	"bytes"
-
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"mime"
 	"net"
 	"net/http"
+	"runtime/debug"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/openebs/mayaserver/structs"
 	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
 )
 
 const (
 	// ErrInvalidMethod is used if the HTTP method is not supported
 	ErrInvalidMethod = "Invalid method"
+
+	// RequestIDHeader is the header used to correlate a request across
+	// the logs. It is honored if set by the caller & is always set on
+	// the response.
+	RequestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLen is the longest request ID accepted from a caller
+	maxRequestIDLen = 128
+
+	// maxYAMLBodyBytes is the largest YAML request body converted to
+	// JSON. Larger bodies are rejected with a 413.
+	maxYAMLBodyBytes = 1 << 20
 )
 
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
 var (
 	// jsonHandle and jsonHandlePretty are the codec handles to JSON encode
 	// structs. The pretty handle will add indents for easier human consumption.
@@ -37,6 +60,16 @@ type HTTPServer struct {
 	listener net.Listener
 	logger   *log.Logger
 	addr     string
+
+	// server serves the mux over the listener
+	server *http.Server
+
+	// limiter throttles the requests, it is nil if they are not limited
+	limiter *requestLimiter
+
+	// tlsConfig is served to incoming connections if TLS is enabled
+	tlsConfig *tls.Config
+	tlsLock   sync.RWMutex
 }
 
 // NewHTTPServer starts new HTTP server over Maya server
@@ -51,23 +84,6 @@ func NewHTTPServer(maya *MayaServer, config *MayaConfig, logOutput io.Writer) (*
 		return nil, fmt.Errorf("failed to start HTTP listener: %v", err)
 	}
 
-	// If TLS is enabled, wrap the listener with a TLS listener
-	//if config.TLSConfig.EnableHTTP {
-	//	tlsConf := &tlsutil.Config{
-	//		VerifyIncoming:       false,
-	//		VerifyOutgoing:       true,
-	//		VerifyServerHostname: config.TLSConfig.VerifyServerHostname,
-	//		CAFile:               config.TLSConfig.CAFile,
-	//		CertFile:             config.TLSConfig.CertFile,
-	//		KeyFile:              config.TLSConfig.KeyFile,
-	//	}
-	//	tlsConfig, err := tlsConf.IncomingTLSConfig()
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	ln = tls.NewListener(tcpKeepAliveListener{ln.(*net.TCPListener)}, tlsConfig)
-	//}
-
 	// Create the mux
 	mux := http.NewServeMux()
 
@@ -78,11 +94,46 @@ func NewHTTPServer(maya *MayaServer, config *MayaConfig, logOutput io.Writer) (*
 		listener: ln,
 		logger:   maya.logger,
 		addr:     ln.Addr().String(),
+		limiter:  newRequestLimiter(config.HTTP),
 	}
+
+	// If TLS is enabled, wrap the listener with a TLS listener
+	if config.TLSConfig.EnableHTTP {
+		tlsConfig, err := config.TLSConfig.IncomingTLSConfig()
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		srv.tlsConfig = tlsConfig
+
+		// The TLS config is looked up per connection so that it can be
+		// reloaded without dropping the listener
+		ln = tls.NewListener(tcpKeepAliveListener{ln.(*net.TCPListener)}, &tls.Config{
+			GetConfigForClient: srv.getTLSConfig,
+		})
+		srv.listener = ln
+	}
+
 	srv.registerHandlers(config.ServiceProvider, config.EnableDebug)
 
+	// Let browsers call the API from the allowed origins
+	handler := http.Handler(mux)
+	if config.CORS != nil && len(config.CORS.AllowedOrigins) > 0 {
+		handler = newCORSHandler(config.CORS, handler)
+	}
+
 	// Start the server
-	go http.Serve(ln, gziphandler.GzipHandler(mux))
+	httpServer := &http.Server{
+		Handler: gziphandler.GzipHandler(handler),
+	}
+	if config.HTTP != nil {
+		httpServer.ReadTimeout = config.HTTP.ReadTimeout
+		httpServer.WriteTimeout = config.HTTP.WriteTimeout
+		httpServer.IdleTimeout = config.HTTP.IdleTimeout
+		httpServer.MaxHeaderBytes = config.HTTP.MaxHeaderBytes
+	}
+	srv.server = httpServer
+	maya.crashReporter.Go(func() { httpServer.Serve(ln) })
 	return srv, nil
 }
 
@@ -103,6 +154,37 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 	return tc, nil
 }
 
+// getTLSConfig returns the current TLS config for an incoming connection
+func (s *HTTPServer) getTLSConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.tlsLock.RLock()
+	defer s.tlsLock.RUnlock()
+	return s.tlsConfig, nil
+}
+
+// ReloadTLS re-reads the certificate, key & CA files of the given TLS
+// config & serves them to new connections. Enabling or disabling TLS
+// requires a restart of the HTTP server.
+func (s *HTTPServer) ReloadTLS(config *TLSConfig) error {
+	s.tlsLock.Lock()
+	defer s.tlsLock.Unlock()
+
+	if (s.tlsConfig != nil) != config.EnableHTTP {
+		return fmt.Errorf("enabling or disabling TLS requires a restart")
+	}
+	if !config.EnableHTTP {
+		return nil
+	}
+
+	tlsConfig, err := config.IncomingTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	s.logger.Printf("[INFO] http: Reloaded TLS configuration")
+	s.tlsConfig = tlsConfig
+	return nil
+}
+
 // Shutdown is used to shutdown the HTTP server
 func (s *HTTPServer) Shutdown() {
 	if s != nil {
@@ -117,6 +199,7 @@ func (s *HTTPServer) registerHandlers(serviceProvider string, enableDebug bool)
 	// NOTE - The curried func (due to wrap) is set as mux handler
 	// NOTE - The original handler is passed as a func to the wrap method
 	s.mux.HandleFunc("/latest/meta-data/", s.wrap(s.MetaSpecificRequest))
+	s.mux.HandleFunc(openAPIPath, s.wrap(s.OpenAPIRequest))
 }
 
 // HTTPCodedError is used to provide the HTTP error code
@@ -150,25 +233,37 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 		// some book keeping stuff
 		setHeaders(resp, s.maya.config.HTTPAPIResponseHeaders)
 		reqURL := req.URL.String()
+		reqID := requestID(req)
+		resp.Header().Set(RequestIDHeader, reqID)
+		req = req.WithContext(context.WithValue(req.Context(), requestIDKey{}, reqID))
 		start := time.Now()
+		code := http.StatusOK
+		phases := &requestPhases{last: start}
 		defer func() {
-			s.logger.Printf("[DEBUG] http: Request %v (%v)", reqURL, time.Now().Sub(start))
+			elapsed := time.Now().Sub(start)
+			s.logger.Printf("[DEBUG] http: Request %v (%v), request id: %s", reqURL, elapsed, reqID)
+			if threshold := s.slowRequestThreshold(); threshold > 0 && elapsed > threshold {
+				s.logger.Printf("[WARN] http: Slow request %s %v (%v > %v), request id: %s, status: %d, phases: %s",
+					req.Method, reqURL, elapsed, threshold, reqID, code, phases)
+			}
 		}()
 
-		// Original handler is invoked
-		obj, err := handler(resp, req)
+		// Original handler is invoked, unless the request is throttled
+		obj, err := s.invoke(handler, resp, req)
+		phases.mark("handler")
 
 		// Check for an error & set it as an http error
 		// Below err block for re-usability
 	HAS_ERR:
 		if err != nil {
-			s.logger.Printf("[ERR] http: Request %v, error: %v", reqURL, err)
-			code := 500
+			s.logger.Printf("[ERR] http: Request %v, request id: %s, error: %v", reqURL, reqID, err)
+			code = 500
 			if http, ok := err.(HTTPCodedError); ok {
 				code = http.Code()
 			}
 			resp.WriteHeader(code)
 			resp.Write([]byte(err.Error()))
+			phases.mark("write")
 			return
 		}
 
@@ -193,24 +288,351 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 				err = enc.Encode(obj)
 			}
 
+			phases.mark("encode")
+
 			// err is handled for both pretty & plain
 			if err != nil {
 				goto HAS_ERR
 			}
+
+			// Trim the response down to the requested fields
+			if fields := req.URL.Query().Get("fields"); fields != "" {
+				var out []byte
+				out, err = projectJSON(buf.Bytes(), strings.Split(fields, ","), prettyPrint)
+				if err != nil {
+					goto HAS_ERR
+				}
+				buf.Reset()
+				buf.Write(out)
+			}
+
+			// The JSON is converted if the client asked for YAML, so that
+			// both have the same field names
+			if acceptsYAML(req) {
+				var out []byte
+				out, err = jsonToYAML(buf.Bytes())
+				if err != nil {
+					goto HAS_ERR
+				}
+				resp.Header().Set("Content-Type", "application/yaml")
+				resp.Write(out)
+				phases.mark("write")
+				return
+			}
+
 			// no error, set the response as json
 			resp.Header().Set("Content-Type", "application/json")
 			resp.Write(buf.Bytes())
+			phases.mark("write")
 		}
 	}
 	return f
 }
 
+// invoke calls the handler, unless the request is throttled. Handlers only
+// decode JSON, so YAML bodies of admitted requests are converted first. A
+// panic of the handler is reported as a crash & turned into a 500 error,
+// so that the server keeps serving.
+func (s *HTTPServer) invoke(handler func(resp http.ResponseWriter, req *http.Request) (interface{}, error),
+	resp http.ResponseWriter, req *http.Request) (obj interface{}, err error) {
+
+	release, err := s.limiter.acquire(resp, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deferred first so that it runs once the limiter is released
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		if path := s.maya.crashReporter.ReportRecovered(r, debug.Stack()); path != "" {
+			s.logger.Printf("[ERR] http: Request %v panicked: %v, crash report written to %s", req.URL, r, path)
+		} else {
+			s.logger.Printf("[ERR] http: Request %v panicked: %v\n%s", req.URL, r, debug.Stack())
+		}
+		obj, err = nil, CodedError(500, "Internal server error")
+	}()
+	defer release()
+
+	if err := convertYAMLBody(resp, req); err != nil {
+		return nil, err
+	}
+
+	return handler(resp, req)
+}
+
+// slowRequestThreshold returns the latency above which requests are
+// logged as slow. Zero disables the logging of slow requests.
+func (s *HTTPServer) slowRequestThreshold() time.Duration {
+	if s.maya.config.HTTP == nil {
+		return 0
+	}
+	return s.maya.config.HTTP.SlowRequestThreshold
+}
+
+// requestPhases records the time spent in each phase of a request, so
+// that the logs of a slow request tell where the time went.
+type requestPhases struct {
+	last   time.Time
+	phases []string
+}
+
+// mark ends the current phase & names it.
+func (p *requestPhases) mark(name string) {
+	now := time.Now()
+	p.phases = append(p.phases, fmt.Sprintf("%s=%v", name, now.Sub(p.last)))
+	p.last = now
+}
+
+func (p *requestPhases) String() string {
+	return strings.Join(p.phases, " ")
+}
+
+// requestID returns the request ID provided by the caller, or generates
+// a new one if none or an invalid one was provided.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+
+	id, err := generateUUID()
+	if err != nil {
+		// Requests are still served, just without correlation
+		return "unknown"
+	}
+	return id
+}
+
+// validRequestID verifies a caller provided request ID is safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the ID of the request the given context belongs to.
+// This is meant to be passed along with the context to any calls made on
+// behalf of the request, so that their logs can be correlated.
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
 // decodeBody is used to decode a JSON request body
 func decodeBody(req *http.Request, out interface{}) error {
 	dec := json.NewDecoder(req.Body)
 	return dec.Decode(&out)
 }
 
+// convertYAMLBody converts a YAML request body to JSON, so that the
+// handlers of all the endpoints accept YAML bodies. A body larger than
+// maxYAMLBodyBytes results in a 413 error & one that is not valid YAML in
+// a 400 error.
+func convertYAMLBody(resp http.ResponseWriter, req *http.Request) error {
+	if req.Body == nil || !isYAMLMediaType(req.Header.Get("Content-Type")) {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(resp, req.Body, maxYAMLBodyBytes))
+	req.Body.Close()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return CodedError(http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("YAML request body larger than %d bytes", maxYAMLBodyBytes))
+		}
+		return CodedError(400, fmt.Sprintf("Failed to read request body: %v", err))
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		body, err = yamlToJSON(body)
+		if err != nil {
+			return CodedError(400, fmt.Sprintf("Invalid YAML request body: %v", err))
+		}
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// projectJSON trims a JSON document down to the given fields. A field is a
+// dot separated path, e.g. status.phase. Lists are projected element by
+// element & scalars are left untouched.
+func projectJSON(js []byte, fields []string, pretty bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var paths [][]string
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			paths = append(paths, strings.Split(field, "."))
+		}
+	}
+	if len(paths) == 0 {
+		return js, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if pretty {
+		enc.SetIndent("", "    ")
+	}
+	if err := enc.Encode(projectValue(v, paths)); err != nil {
+		return nil, err
+	}
+
+	// Only the pretty output ends with a newline, as done by the codec
+	if !pretty {
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// projectValue keeps the given paths of an object, or of every object of a
+// list.
+func projectValue(v interface{}, paths [][]string) interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, elem := range t {
+			out[i] = projectValue(elem, paths)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		for _, path := range paths {
+			projectPath(t, out, path)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// projectPath copies the value at the given path of src into dst.
+func projectPath(src, dst map[string]interface{}, path []string) {
+	val, ok := src[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = val
+		return
+	}
+
+	switch t := val.(type) {
+	case map[string]interface{}:
+		sub, ok := dst[path[0]].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			dst[path[0]] = sub
+		}
+		projectPath(t, sub, path[1:])
+	case []interface{}:
+		list, ok := dst[path[0]].([]interface{})
+		if !ok {
+			list = make([]interface{}, len(t))
+			for i := range list {
+				list[i] = make(map[string]interface{})
+			}
+			dst[path[0]] = list
+		}
+		for i, elem := range t {
+			if m, ok := elem.(map[string]interface{}); ok {
+				projectPath(m, list[i].(map[string]interface{}), path[1:])
+			}
+		}
+	}
+}
+
+// isYAMLMediaType returns true if the given media type, e.g. taken from a
+// Content-Type header, is a YAML one.
+func isYAMLMediaType(mediaType string) bool {
+	mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+	switch strings.ToLower(mediaType) {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// acceptsYAML returns true if the client prefers a YAML response, i.e. if
+// a YAML media type is listed in its Accept header before any JSON or
+// wildcard one.
+func acceptsYAML(req *http.Request) bool {
+	for _, accept := range req.Header["Accept"] {
+		for _, mediaType := range strings.Split(accept, ",") {
+			if !acceptable(mediaType) {
+				continue
+			}
+			if isYAMLMediaType(mediaType) {
+				return true
+			}
+			mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+			if mediaType == "application/json" || strings.HasSuffix(mediaType, "*") {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// jsonToYAML converts a JSON document to YAML.
+func jsonToYAML(js []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// acceptable returns false if the given media type of an Accept header is
+// excluded with q=0.
+func acceptable(mediaType string) bool {
+	_, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return true
+	}
+	q, err := strconv.ParseFloat(params["q"], 64)
+	return err != nil || q > 0
+}
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(y []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(y, &v); err != nil {
+		return nil, err
+	}
+	v, err := convertYAMLValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
 // setIndex is used to set the index response header
 func setIndex(resp http.ResponseWriter, index uint64) {
 	resp.Header().Set("X-Maya-Index", strconv.FormatUint(index, 10))
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// defaultCORSMethods are the methods allowed in cross-origin requests
+	// if none are configured.
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE"}
+
+	// defaultCORSHeaders are the request headers allowed in cross-origin
+	// requests if none are configured.
+	defaultCORSHeaders = []string{"Content-Type", RequestIDHeader}
+)
+
+// corsHandler implements CORS on top of the wrapped handler, so that the
+// OpenEBS dashboard can call Maya server right from the browser.
+type corsHandler struct {
+	next http.Handler
+
+	// origins holds the allowed origins. anyOrigin is set if any origin
+	// is allowed.
+	origins   map[string]struct{}
+	anyOrigin bool
+
+	methods map[string]struct{}
+
+	// allowMethods, allowHeaders & maxAge are the values of the headers
+	// sent in reply to preflight requests.
+	allowMethods string
+	allowHeaders string
+	maxAge       string
+}
+
+// newCORSHandler returns a handler that applies the given CORS config to
+// the requests served by next.
+func newCORSHandler(config *CORSConfig, next http.Handler) http.Handler {
+	h := &corsHandler{
+		next:    next,
+		origins: make(map[string]struct{}),
+		methods: make(map[string]struct{}),
+	}
+
+	for _, origin := range splitList(config.AllowedOrigins) {
+		if origin == "*" {
+			h.anyOrigin = true
+		}
+		h.origins[strings.ToLower(strings.TrimRight(origin, "/"))] = struct{}{}
+	}
+
+	methods := splitList(config.AllowedMethods)
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := make([]string, 0, len(methods))
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		h.methods[method] = struct{}{}
+		allowMethods = append(allowMethods, method)
+	}
+	h.allowMethods = strings.Join(allowMethods, ", ")
+
+	headers := splitList(config.AllowedHeaders)
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	h.allowHeaders = strings.Join(headers, ", ")
+
+	if config.MaxAge > 0 {
+		h.maxAge = strconv.FormatInt(int64(config.MaxAge.Seconds()), 10)
+	}
+	return h
+}
+
+func (h *corsHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		// Not a cross-origin request
+		h.next.ServeHTTP(resp, req)
+		return
+	}
+
+	preflight := req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != ""
+	resp.Header().Add("Vary", "Origin")
+
+	if !h.allowed(origin) {
+		if preflight {
+			resp.WriteHeader(http.StatusForbidden)
+			return
+		}
+		// Let the browser block the response
+		h.next.ServeHTTP(resp, req)
+		return
+	}
+
+	if h.anyOrigin {
+		resp.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		resp.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if !preflight {
+		resp.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+		h.next.ServeHTTP(resp, req)
+		return
+	}
+
+	method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	if _, ok := h.methods[method]; !ok {
+		resp.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	resp.Header().Set("Access-Control-Allow-Methods", h.allowMethods)
+	resp.Header().Set("Access-Control-Allow-Headers", h.allowHeaders)
+	if h.maxAge != "" {
+		resp.Header().Set("Access-Control-Max-Age", h.maxAge)
+	}
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// allowed returns true if requests from the given origin are allowed.
+func (h *corsHandler) allowed(origin string) bool {
+	if h.anyOrigin {
+		return true
+	}
+	_, ok := h.origins[strings.ToLower(strings.TrimRight(origin, "/"))]
+	return ok
+}
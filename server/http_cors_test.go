@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSHandler(t *testing.T) {
+	var served int
+	next := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		served++
+	})
+	h := newCORSHandler(&CORSConfig{
+		AllowedOrigins: []string{"https://dashboard.openebs.io, http://localhost:8080"},
+		AllowedMethods: []string{"get", "POST"},
+		MaxAge:         10 * time.Minute,
+	}, next)
+
+	// Same origin requests are left alone
+	req, _ := http.NewRequest("GET", "/latest/meta-data/", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if served != 1 || resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("bad: served %d, headers %v", served, resp.Header())
+	}
+
+	// Allowed origin
+	req.Header.Set("Origin", "http://localhost:8080")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if served != 2 {
+		t.Fatalf("expected request to be served")
+	}
+	if v := resp.Header().Get("Access-Control-Allow-Origin"); v != "http://localhost:8080" {
+		t.Fatalf("bad allow origin: %q", v)
+	}
+	if v := resp.Header().Get("Access-Control-Expose-Headers"); v != RequestIDHeader {
+		t.Fatalf("bad expose headers: %q", v)
+	}
+	if v := resp.Header().Get("Vary"); v != "Origin" {
+		t.Fatalf("bad vary: %q", v)
+	}
+
+	// Other origins are not allowed
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if v := resp.Header().Get("Access-Control-Allow-Origin"); v != "" {
+		t.Fatalf("bad allow origin: %q", v)
+	}
+
+	// Preflight
+	req, _ = http.NewRequest("OPTIONS", "/latest/meta-data/", nil)
+	req.Header.Set("Origin", "https://dashboard.openebs.io")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	resp = httptest.NewRecorder()
+	served = 0
+	h.ServeHTTP(resp, req)
+	if served != 0 || resp.Code != 204 {
+		t.Fatalf("bad: served %d, code %d", served, resp.Code)
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://dashboard.openebs.io",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Content-Type, X-Request-ID",
+		"Access-Control-Max-Age":       "600",
+	}
+	for k, v := range expected {
+		if got := resp.Header().Get(k); got != v {
+			t.Fatalf("header %s: expected %q, got %q", k, v, got)
+		}
+	}
+
+	// Preflight of a method that is not allowed
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != 403 {
+		t.Fatalf("expected 403, got: %d", resp.Code)
+	}
+
+	// Preflight from an origin that is not allowed
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != 403 {
+		t.Fatalf("expected 403, got: %d", resp.Code)
+	}
+}
+
+func TestCORSHandler_AnyOrigin(t *testing.T) {
+	h := newCORSHandler(&CORSConfig{AllowedOrigins: []string{"*"}},
+		http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+
+	req, _ := http.NewRequest("OPTIONS", "/latest/meta-data/", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != 204 {
+		t.Fatalf("expected 204, got: %d", resp.Code)
+	}
+	if v := resp.Header().Get("Access-Control-Allow-Origin"); v != "*" {
+		t.Fatalf("bad allow origin: %q", v)
+	}
+	if v := resp.Header().Get("Access-Control-Allow-Methods"); v != "GET, HEAD, POST, PUT, DELETE" {
+		t.Fatalf("bad allow methods: %q", v)
+	}
+	if v := resp.Header().Get("Access-Control-Max-Age"); v != "" {
+		t.Fatalf("expected no max age, got: %q", v)
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// limiterSweepInterval is how often the buckets of idle clients are
+// dropped from the requestLimiter.
+const limiterSweepInterval = time.Minute
+
+// unlimitedPaths are never throttled, so that the liveness & readiness
+// probes do not fail while Maya server is busy.
+var unlimitedPaths = map[string]struct{}{
+	"/latest/meta-data/health": {},
+	"/latest/meta-data/ready":  {},
+}
+
+// requestLimiter throttles the requests served by the HTTP server. Each
+// client, identified by its remote IP, gets a token bucket that refills at
+// rate tokens per second. The number of requests served at the same time
+// may also be capped, irrespective of the client.
+type requestLimiter struct {
+	rate  float64
+	burst float64
+
+	// sem holds a token per request in flight. It is nil if the number
+	// of concurrent requests is not capped.
+	sem chan struct{}
+
+	lock      sync.Mutex
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket holds the tokens left to a client as of the last update.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRequestLimiter returns a requestLimiter for the given config, or nil
+// if the config does not limit the requests.
+func newRequestLimiter(config *HTTPConfig) *requestLimiter {
+	if config == nil || (config.RateLimit <= 0 && config.MaxConcurrentRequests <= 0) {
+		return nil
+	}
+
+	l := &requestLimiter{
+		rate:      config.RateLimit,
+		burst:     float64(config.RateLimitBurst),
+		clients:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+	if l.burst <= 0 {
+		l.burst = math.Max(1, math.Ceil(l.rate))
+	}
+	if config.MaxConcurrentRequests > 0 {
+		l.sem = make(chan struct{}, config.MaxConcurrentRequests)
+	}
+	return l
+}
+
+// acquire admits the given request or returns a 429 error, in which case
+// the Retry-After header is set on the response. The returned func must be
+// called once an admitted request has been served.
+func (l *requestLimiter) acquire(resp http.ResponseWriter, req *http.Request) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if _, ok := unlimitedPaths[req.URL.Path]; ok {
+		return func() {}, nil
+	}
+
+	if l.rate > 0 {
+		if wait := l.take(clientIP(req), time.Now()); wait > 0 {
+			setRetryAfter(resp, wait)
+			return nil, CodedError(http.StatusTooManyRequests,
+				fmt.Sprintf("Rate limit exceeded, retry after %v", wait))
+		}
+	}
+
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			setRetryAfter(resp, time.Second)
+			return nil, CodedError(http.StatusTooManyRequests,
+				"Too many concurrent requests, retry later")
+		}
+		return func() { <-l.sem }, nil
+	}
+
+	return func() {}, nil
+}
+
+// take takes a token from the bucket of the given client. If the bucket is
+// empty, it returns how long the client has to wait for the next token.
+func (l *requestLimiter) take(client string, now time.Time) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// sweep drops the buckets that have refilled, as their clients have been
+// idle long enough to be treated like new ones.
+func (l *requestLimiter) sweep(now time.Time) {
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// clientIP returns the IP address of the client that sent the request.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// setRetryAfter sets the Retry-After header, rounded up to the second.
+func setRetryAfter(resp http.ResponseWriter, wait time.Duration) {
+	secs := int64(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	resp.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
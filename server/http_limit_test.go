@@ -0,0 +1,181 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewRequestLimiter(t *testing.T) {
+	if l := newRequestLimiter(nil); l != nil {
+		t.Fatalf("expected no limiter, got: %#v", l)
+	}
+	if l := newRequestLimiter(&HTTPConfig{}); l != nil {
+		t.Fatalf("expected no limiter, got: %#v", l)
+	}
+
+	// The burst defaults to the rate
+	l := newRequestLimiter(&HTTPConfig{RateLimit: 2.5})
+	if l == nil || l.burst != 3 || l.sem != nil {
+		t.Fatalf("bad: %#v", l)
+	}
+
+	l = newRequestLimiter(&HTTPConfig{MaxConcurrentRequests: 4})
+	if l == nil || l.rate != 0 || cap(l.sem) != 4 {
+		t.Fatalf("bad: %#v", l)
+	}
+}
+
+func TestRequestLimiter_Take(t *testing.T) {
+	l := newRequestLimiter(&HTTPConfig{RateLimit: 1, RateLimitBurst: 2})
+	now := time.Now()
+
+	// The burst is served right away
+	for i := 0; i < 2; i++ {
+		if wait := l.take("10.0.0.1", now); wait != 0 {
+			t.Fatalf("request %d: expected no wait, got: %v", i, wait)
+		}
+	}
+	if wait := l.take("10.0.0.1", now); wait != time.Second {
+		t.Fatalf("expected to wait 1s, got: %v", wait)
+	}
+
+	// Other clients are not affected
+	if wait := l.take("10.0.0.2", now); wait != 0 {
+		t.Fatalf("expected no wait, got: %v", wait)
+	}
+
+	// Tokens are refilled over time
+	if wait := l.take("10.0.0.1", now.Add(500*time.Millisecond)); wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got: %v", wait)
+	}
+	if wait := l.take("10.0.0.1", now.Add(time.Second)); wait != 0 {
+		t.Fatalf("expected no wait, got: %v", wait)
+	}
+
+	// Idle clients are swept
+	l.take("10.0.0.3", now.Add(2*limiterSweepInterval))
+	if _, ok := l.clients["10.0.0.1"]; ok {
+		t.Fatalf("expected idle client to be swept: %#v", l.clients)
+	}
+	if _, ok := l.clients["10.0.0.3"]; !ok {
+		t.Fatalf("expected active client to be tracked: %#v", l.clients)
+	}
+}
+
+func TestHTTPServer_RateLimit(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.HTTP.RateLimit = 0.001
+		mc.HTTP.RateLimitBurst = 1
+	})
+	defer s.Cleanup()
+
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return "ok", nil
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	resp := httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got: %d", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 429 {
+		t.Fatalf("expected 429, got: %d", resp.Code)
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected a Retry-After header")
+	}
+
+	// Probes are never throttled
+	for _, path := range []string{"/latest/meta-data/health", "/latest/meta-data/ready"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+
+		resp = httptest.NewRecorder()
+		s.Server.wrap(s.Server.MetaSpecificRequest)(resp, req)
+		if resp.Code != 200 {
+			t.Fatalf("path: %s, expected 200, got: %d", path, resp.Code)
+		}
+	}
+}
+
+func TestHTTPServer_MaxConcurrentRequests(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.HTTP.MaxConcurrentRequests = 1
+	})
+	defer s.Cleanup()
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	blocking := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		close(started)
+		<-unblock
+		return "ok", nil
+	}
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return "ok", nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest("GET", "/v1/kv/blocking", nil)
+		s.Server.wrap(blocking)(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 429 {
+		t.Fatalf("expected 429, got: %d", resp.Code)
+	}
+	if v := resp.Header().Get("Retry-After"); v != "1" {
+		t.Fatalf("expected Retry-After 1, got: %q", v)
+	}
+
+	// Requests are served again once the cap is no longer reached
+	close(unblock)
+	<-done
+
+	resp = httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got: %d", resp.Code)
+	}
+}
+
+func TestHTTPServer_MaxConcurrentRequests_Panic(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.HTTP.MaxConcurrentRequests = 1
+	})
+	defer s.Cleanup()
+
+	panicking := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		panic("boom")
+	}
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return "ok", nil
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(panicking)(resp, req)
+	if resp.Code != 500 {
+		t.Fatalf("expected 500, got: %d", resp.Code)
+	}
+
+	// The slot of the panicking request is released
+	resp = httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got: %d", resp.Code)
+	}
+}
@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/openebs/mayaserver/structs"
+	"github.com/openebs/mayaserver/util/tlsutil"
 	"github.com/ugorji/go/codec"
 )
 
@@ -421,3 +425,457 @@ func encodeReq(obj interface{}) io.ReadCloser {
 	enc.Encode(obj)
 	return ioutil.NopCloser(buf)
 }
+
+// makeTLSTestClient returns an HTTPS client that trusts the test CA. The
+// test client certificate is presented if withCert is set.
+func makeTLSTestClient(t *testing.T, withCert bool) *http.Client {
+	conf := &tlsutil.Config{
+		VerifyOutgoing: true,
+		CAFile:         "../mockit/tls/ca.pem",
+	}
+	if withCert {
+		conf.CertFile = "../mockit/tls/client.pem"
+		conf.KeyFile = "../mockit/tls/client-key.pem"
+	}
+	tlsConf, err := conf.OutgoingTLSConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConf},
+	}
+}
+
+func TestHTTPServer_TLS(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.TLSConfig = &TLSConfig{
+			EnableHTTP: true,
+			CAFile:     "../mockit/tls/ca.pem",
+			CertFile:   "../mockit/tls/server.pem",
+			KeyFile:    "../mockit/tls/server-key.pem",
+		}
+	})
+	defer s.Cleanup()
+
+	url := "https://" + s.Server.addr + "/latest/meta-data/instance-id"
+
+	// Served over TLS without a client certificate
+	resp, err := makeTLSTestClient(t, false).Get(url)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v", resp.StatusCode)
+	}
+
+	// Reload to require client certificates
+	conf := *s.Maya.config.TLSConfig
+	conf.VerifyIncoming = true
+	if err := s.Server.ReloadTLS(&conf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if resp, err := makeTLSTestClient(t, false).Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected tls error, got: %v", resp.StatusCode)
+	}
+
+	resp, err = makeTLSTestClient(t, true).Get(url)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v", resp.StatusCode)
+	}
+
+	// Fails to disable TLS on reload
+	if err := s.Server.ReloadTLS(&TLSConfig{}); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+
+	// Fails to reload with a missing key pair
+	conf.CertFile = ""
+	if err := s.Server.ReloadTLS(&conf); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
+
+func TestHTTPServer_TLSInvalidConfig(t *testing.T) {
+	dir, maya := makeMayaServer(t, func(mc *MayaConfig) {
+		mc.TLSConfig = &TLSConfig{
+			EnableHTTP: true,
+		}
+	})
+	defer os.RemoveAll(dir)
+	defer maya.Shutdown()
+
+	if _, err := NewHTTPServer(maya, maya.config, ioutil.Discard); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
+
+func TestRequestID_Generated(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	var ctxID string
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		ctxID = RequestID(req.Context())
+		return "noop", nil
+	}
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+	s.Server.wrap(handler)(resp, req)
+
+	header := resp.Header().Get(RequestIDHeader)
+	if header == "" {
+		t.Fatalf("expected a generated request id")
+	}
+	if ctxID != header {
+		t.Fatalf("expected request id %q in context, got: %q", header, ctxID)
+	}
+
+	// Every request gets its own ID
+	resp2 := httptest.NewRecorder()
+	s.Server.wrap(handler)(resp2, req)
+	if resp2.Header().Get(RequestIDHeader) == header {
+		t.Fatalf("expected a new request id, got: %q", header)
+	}
+}
+
+func TestRequestID_Honored(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	var ctxID string
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		ctxID = RequestID(req.Context())
+		return nil, CodedError(404, "not found")
+	}
+
+	cases := []struct {
+		ID     string
+		Honors bool
+	}{
+		{"my-trace-1234", true},
+		{"has spaces", false},
+		{"bad\nnewline", false},
+		{strings.Repeat("x", maxRequestIDLen+1), false},
+	}
+
+	for _, tc := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+		req.Header.Set(RequestIDHeader, tc.ID)
+		s.Server.wrap(handler)(resp, req)
+
+		header := resp.Header().Get(RequestIDHeader)
+		if (header == tc.ID) != tc.Honors {
+			t.Fatalf("id: %q, honors: %v, got: %q", tc.ID, tc.Honors, header)
+		}
+		if ctxID != header {
+			t.Fatalf("expected request id %q in context, got: %q", header, ctxID)
+		}
+	}
+}
+
+func TestHTTPServer_SlowRequestLog(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.HTTP.SlowRequestThreshold = 10 * time.Millisecond
+	})
+	defer s.Cleanup()
+
+	var buf bytes.Buffer
+	s.Server.logger = log.New(&buf, "", 0)
+
+	fast := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return "fast", nil
+	}
+	slow := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return nil, CodedError(404, "not found")
+	}
+
+	// Fast requests are not reported
+	req, _ := http.NewRequest("GET", "/v1/kv/fast", nil)
+	s.Server.wrap(fast)(httptest.NewRecorder(), req)
+	if strings.Contains(buf.String(), "Slow request") {
+		t.Fatalf("unexpected slow request log: %s", buf.String())
+	}
+
+	// Slow requests are reported along with their phases
+	req, _ = http.NewRequest("GET", "/v1/kv/slow", nil)
+	req.Header.Set(RequestIDHeader, "slow-1234")
+	s.Server.wrap(slow)(httptest.NewRecorder(), req)
+
+	out := buf.String()
+	for _, expected := range []string{
+		"[WARN] http: Slow request GET /v1/kv/slow",
+		"request id: slow-1234",
+		"status: 404",
+		"phases: handler=",
+		"write=",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in logs, got: %s", expected, out)
+		}
+	}
+}
+
+func TestYAMLResponse(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	type response struct {
+		Name  string   `json:"name"`
+		Port  int      `json:"port"`
+		Zones []string `json:"zones"`
+	}
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return &response{Name: "maya", Port: 5656, Zones: []string{"a", "b"}}, nil
+	}
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+	req.Header.Set("Accept", "application/yaml")
+	s.Server.wrap(handler)(resp, req)
+
+	if contentType := resp.Header().Get("Content-Type"); contentType != "application/yaml" {
+		t.Fatalf("bad content type: %s", contentType)
+	}
+	expected := "name: maya\nport: 5656\nzones:\n- a\n- b\n"
+	if resp.Body.String() != expected {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", expected, resp.Body.String())
+	}
+}
+
+func TestAcceptsYAML(t *testing.T) {
+	cases := []struct {
+		Accept string
+		YAML   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"*/*", false},
+		{"application/yaml", true},
+		{"text/yaml; charset=utf-8", true},
+		{"application/x-yaml, application/json", true},
+		{"application/json, application/yaml", false},
+		{"application/yaml;q=0", false},
+		{"application/yaml; q=0.0, application/x-yaml;q=0.5", true},
+		{"application/yaml;q=0, application/json", false},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest("GET", "/v1/kv/key", nil)
+		if tc.Accept != "" {
+			req.Header.Set("Accept", tc.Accept)
+		}
+		if acceptsYAML(req) != tc.YAML {
+			t.Fatalf("accept: %q, expected yaml: %v", tc.Accept, tc.YAML)
+		}
+	}
+}
+
+func TestYAMLRequestBody(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	type body struct {
+		Name     string `json:"name"`
+		Replicas int    `json:"replicas"`
+	}
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		var out body
+		if err := decodeBody(req, &out); err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+		return out, nil
+	}
+
+	cases := []struct {
+		ContentType string
+		Body        string
+	}{
+		{"application/json", `{"name": "vol1", "replicas": 3}`},
+		{"application/yaml", "name: vol1\nreplicas: 3\n"},
+		{"application/x-yaml; charset=utf-8", "name: vol1\nreplicas: 3\n"},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest("PUT", "/v1/kv/key", strings.NewReader(tc.Body))
+		req.Header.Set("Content-Type", tc.ContentType)
+
+		resp := httptest.NewRecorder()
+		s.Server.wrap(handler)(resp, req)
+		if resp.Code != 200 {
+			t.Fatalf("content type: %s, expected 200, got: %d, %s", tc.ContentType, resp.Code, resp.Body.String())
+		}
+
+		var out body
+		if err := json.Unmarshal(resp.Body.Bytes(), &out); err != nil {
+			t.Fatalf("content type: %s, err: %v", tc.ContentType, err)
+		}
+		if out.Name != "vol1" || out.Replicas != 3 {
+			t.Fatalf("content type: %s, bad: %#v", tc.ContentType, out)
+		}
+	}
+
+	// Invalid YAML is rejected before reaching the handler
+	req, _ := http.NewRequest("PUT", "/v1/kv/key", strings.NewReader("name: [vol1"))
+	req.Header.Set("Content-Type", "application/yaml")
+	resp := httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 400 {
+		t.Fatalf("expected 400, got: %d", resp.Code)
+	}
+	// Too large bodies are rejected
+	large := "name: " + strings.Repeat("a", maxYAMLBodyBytes) + "\n"
+	req, _ = http.NewRequest("PUT", "/v1/kv/key", strings.NewReader(large))
+	req.Header.Set("Content-Type", "application/yaml")
+	resp = httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, req)
+	if resp.Code != 413 {
+		t.Fatalf("expected 413, got: %d", resp.Code)
+	}
+}
+
+func TestYAMLRequestBody_Throttled(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.HTTP.RateLimit = 0.001
+		mc.HTTP.RateLimitBurst = 1
+	})
+	defer s.Cleanup()
+
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return "ok", nil
+	}
+	newReq := func(body *bytes.Reader) *http.Request {
+		req, _ := http.NewRequest("PUT", "/v1/kv/key", body)
+		req.Header.Set("Content-Type", "application/yaml")
+		req.RemoteAddr = "10.0.0.1:1234"
+		return req
+	}
+
+	body := bytes.NewReader([]byte("name: vol1\n"))
+	s.Server.wrap(handler)(httptest.NewRecorder(), newReq(body))
+	if body.Len() != 0 {
+		t.Fatalf("expected the body of the admitted request to be read")
+	}
+
+	// The body of a throttled request is left unread
+	body = bytes.NewReader([]byte("name: vol1\n"))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(handler)(resp, newReq(body))
+	if resp.Code != 429 {
+		t.Fatalf("expected 429, got: %d", resp.Code)
+	}
+	if body.Len() == 0 {
+		t.Fatalf("expected the body of the throttled request to be left unread")
+	}
+}
+
+func TestProjectJSON(t *testing.T) {
+	doc := `{"name":"vol1","spec":{"capacity":"5G","replicas":3},"status":{"phase":"Running","replicas":[{"id":"r1","mode":"RW"},{"id":"r2","mode":"WO"}]}}`
+
+	cases := []struct {
+		Fields   []string
+		Expected string
+	}{
+		{[]string{"name"}, `{"name":"vol1"}`},
+		{[]string{"name", "spec.capacity"}, `{"name":"vol1","spec":{"capacity":"5G"}}`},
+		{[]string{"spec.capacity", "spec.replicas"}, `{"spec":{"capacity":"5G","replicas":3}}`},
+		{[]string{"status.replicas.id"}, `{"status":{"replicas":[{"id":"r1"},{"id":"r2"}]}}`},
+		{[]string{"unknown", "name.unknown"}, `{}`},
+		{[]string{"", " "}, doc},
+	}
+
+	for _, tc := range cases {
+		out, err := projectJSON([]byte(doc), tc.Fields, false)
+		if err != nil {
+			t.Fatalf("fields: %v, err: %v", tc.Fields, err)
+		}
+		if string(out) != tc.Expected {
+			t.Fatalf("fields: %v\nexpected: %s\nactual:   %s", tc.Fields, tc.Expected, out)
+		}
+	}
+
+	// Lists are projected element by element
+	out, err := projectJSON([]byte(`[{"name":"vol1","size":1},{"name":"vol2","size":2},"vol3"]`), []string{"name"}, false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if expected := `[{"name":"vol1"},{"name":"vol2"},"vol3"]`; string(out) != expected {
+		t.Fatalf("expected: %s\nactual:   %s", expected, out)
+	}
+}
+
+func TestFieldsProjectionViaWrap(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	type status struct {
+		Phase string `json:"phase"`
+	}
+	type volume struct {
+		Name   string `json:"name"`
+		Size   int    `json:"size"`
+		Status status `json:"status"`
+	}
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return []volume{{"vol1", 1, status{"Running"}}, {"vol2", 2, status{"Pending"}}}, nil
+	}
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/kv/key?fields=name,status.phase", nil)
+	s.Server.wrap(handler)(resp, req)
+
+	expected := `[{"name":"vol1","status":{"phase":"Running"}},{"name":"vol2","status":{"phase":"Pending"}}]`
+	if resp.Body.String() != expected {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", expected, resp.Body.String())
+	}
+
+	// Projection applies to YAML too
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/kv/key?fields=name", nil)
+	req.Header.Set("Accept", "application/yaml")
+	s.Server.wrap(handler)(resp, req)
+
+	if expected := "- name: vol1\n- name: vol2\n"; resp.Body.String() != expected {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", expected, resp.Body.String())
+	}
+}
+
+func TestHTTPServer_Timeouts(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.HTTP.ReadTimeout = 100 * time.Millisecond
+		mc.HTTP.WriteTimeout = 2 * time.Second
+		mc.HTTP.IdleTimeout = 3 * time.Second
+		mc.HTTP.MaxHeaderBytes = 4096
+	})
+	defer s.Cleanup()
+
+	srv := s.Server.server
+	if srv.ReadTimeout != 100*time.Millisecond || srv.WriteTimeout != 2*time.Second ||
+		srv.IdleTimeout != 3*time.Second || srv.MaxHeaderBytes != 4096 {
+		t.Fatalf("bad: %#v", srv)
+	}
+
+	// A client that never finishes its request is disconnected
+	conn, err := net.Dial("tcp", s.Server.addr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET /latest/meta-data/ HTTP/1.1\r\n")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Fatalf("expected the server to close the connection, got: %v", err)
+	}
+}
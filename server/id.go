@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// clusterIDFile is the file within data_dir that persists the
+	// cluster ID.
+	clusterIDFile = "cluster-id"
+
+	// nodeIDFile is the file within data_dir that persists the node ID.
+	nodeIDFile = "node-id"
+)
+
+// generateUUID is used to generate a random UUID
+func generateUUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %v", err)
+	}
+
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%12x",
+		buf[0:4],
+		buf[4:6],
+		buf[6:8],
+		buf[8:10],
+		buf[10:16]), nil
+}
+
+// loadOrCreateID returns the ID persisted in the given file. A new ID is
+// generated & persisted if the file does not exist yet. If dir is empty,
+// an ephemeral ID is returned.
+func loadOrCreateID(dir, file string) (string, error) {
+	if dir == "" {
+		return generateUUID()
+	}
+
+	path := filepath.Join(dir, file)
+	if buf, err := ioutil.ReadFile(path); err == nil {
+		id := strings.TrimSpace(string(buf))
+		if id == "" {
+			return "", fmt.Errorf("%s is empty", path)
+		}
+		return id, nil
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	id, err := generateUUID()
+	if err != nil {
+		return "", err
+	}
+
+	if err := persistID(dir, file, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// persistID writes the given ID to the given file, replacing the ID that
+// may have been persisted before. Nothing is written if dir is empty.
+func persistID(dir, file, id string) error {
+	if dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	path := filepath.Join(dir, file)
+	if err := ioutil.WriteFile(path, []byte(id), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateUUID(t *testing.T) {
+	prev, err := generateUUID()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		id, err := generateUUID()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if prev == id {
+			t.Fatalf("Should get a new ID!")
+		}
+
+		matched, err := regexp.MatchString(
+			"[\\da-f]{8}-[\\da-f]{4}-[\\da-f]{4}-[\\da-f]{4}-[\\da-f]{12}", id)
+		if !matched || err != nil {
+			t.Fatalf("expected match %s %v %s", id, matched, err)
+		}
+		prev = id
+	}
+}
+
+func TestLoadOrCreateID(t *testing.T) {
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	// Creates & persists a new ID
+	id, err := loadOrCreateID(dir, nodeIDFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, nodeIDFile))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(buf) != id {
+		t.Fatalf("expected persisted id %q, got: %q", id, string(buf))
+	}
+
+	// Reuses the persisted ID
+	again, err := loadOrCreateID(dir, nodeIDFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if again != id {
+		t.Fatalf("expected id %q, got: %q", id, again)
+	}
+
+	// Fails on an empty ID file
+	if err := ioutil.WriteFile(filepath.Join(dir, clusterIDFile), []byte("\n"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := loadOrCreateID(dir, clusterIDFile); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+
+	// Ephemeral IDs without a data dir
+	if id, err := loadOrCreateID("", nodeIDFile); err != nil || id == "" {
+		t.Fatalf("expected ephemeral id, got: %q, err: %v", id, err)
+	}
+}
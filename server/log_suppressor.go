@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogSuppressor implements io.Writer so it can be used as a log sink.
+// It passes through the first occurrence of a log line & suppresses its
+// identical repetitions for a window. Once the window expires, a notice
+// with the number of suppressed lines is written instead.
+type LogSuppressor struct {
+	sync.Mutex
+	writer     io.Writer
+	window     time.Duration
+	subsystems map[string]time.Duration
+	seen       map[string]*suppressedLog
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// suppressedLog tracks the repetitions of a log line
+type suppressedLog struct {
+	level     string
+	subsystem string
+	message   string
+	expires   time.Time
+	count     int
+}
+
+// NewLogSuppressor creates a LogSuppressor writing to the given writer.
+// Notices of suppressed lines are flushed periodically till Stop is
+// called. A panic of the periodic flush is reported to crashReporter,
+// which may be nil.
+func NewLogSuppressor(w io.Writer, conf *LogSuppression, crashReporter *CrashReporter) *LogSuppressor {
+	l := &LogSuppressor{
+		writer:     w,
+		window:     conf.Window,
+		subsystems: conf.Subsystems,
+		seen:       make(map[string]*suppressedLog),
+		stopCh:     make(chan struct{}),
+	}
+
+	if interval := l.flushInterval(); interval > 0 {
+		crashReporter.Go(func() { l.run(interval) })
+	}
+	return l
+}
+
+// flushInterval returns the smallest positive window, global or of a
+// subsystem, so that no notice is flushed later than a window after it
+// expired. It returns 0 if nothing is suppressed.
+func (l *LogSuppressor) flushInterval() time.Duration {
+	interval := l.window
+	for _, w := range l.subsystems {
+		if w > 0 && (interval <= 0 || w < interval) {
+			interval = w
+		}
+	}
+	if interval < 0 {
+		return 0
+	}
+	return interval
+}
+
+// run periodically flushes the notices of expired windows
+func (l *LogSuppressor) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Flush()
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// Stop flushes all the pending notices & stops the periodic flush
+func (l *LogSuppressor) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopCh)
+	})
+
+	l.Lock()
+	defer l.Unlock()
+	for key, sl := range l.seen {
+		l.writeNotice(sl)
+		delete(l.seen, key)
+	}
+}
+
+// Flush writes the notices of the log lines whose window has expired
+func (l *LogSuppressor) Flush() {
+	l.Lock()
+	defer l.Unlock()
+	l.flush(time.Now())
+}
+
+func (l *LogSuppressor) flush(now time.Time) {
+	for key, sl := range l.seen {
+		if now.Before(sl.expires) {
+			continue
+		}
+		l.writeNotice(sl)
+		delete(l.seen, key)
+	}
+}
+
+// Write is used to implement io.Writer
+func (l *LogSuppressor) Write(p []byte) (int, error) {
+	level, subsystem, message := splitLogLine(p)
+
+	window := l.window
+	if w, ok := l.subsystems[subsystem]; ok {
+		window = w
+	}
+	if window <= 0 || level == "" {
+		return l.writer.Write(p)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	// The timestamp is not part of the key, since it differs
+	// for every repetition
+	key := level + "|" + subsystem + "|" + message
+	now := time.Now()
+	if sl, ok := l.seen[key]; ok {
+		if now.Before(sl.expires) {
+			sl.count++
+			return len(p), nil
+		}
+
+		// The window expired before the periodic flush got to it
+		l.writeNotice(sl)
+	}
+
+	// Other expired windows are left to the periodic flush, as walking
+	// all the lines seen in the window on every write would serialize
+	// logging behind the lock
+	l.seen[key] = &suppressedLog{
+		level:     level,
+		subsystem: subsystem,
+		message:   message,
+		expires:   now.Add(window),
+	}
+	return l.writer.Write(p)
+}
+
+// writeNotice writes a notice for the suppressed repetitions, if any
+func (l *LogSuppressor) writeNotice(sl *suppressedLog) {
+	if sl.count == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(time.Now().Format("2006/01/02 15:04:05.000000 "))
+	fmt.Fprintf(&buf, "[%s] ", sl.level)
+	if sl.subsystem != "" {
+		fmt.Fprintf(&buf, "%s: ", sl.subsystem)
+	}
+	fmt.Fprintf(&buf, "suppressed %d duplicate(s) of: %s\n", sl.count, sl.message)
+	l.writer.Write(buf.Bytes())
+}
+
+// splitLogLine extracts the level, the subsystem & the message of a log
+// line of the form "<timestamp> [LEVEL] subsystem: message". The level
+// is empty if the line does not carry one.
+func splitLogLine(p []byte) (level, subsystem, message string) {
+	x := bytes.IndexByte(p, '[')
+	if x < 0 {
+		return "", "", ""
+	}
+	y := bytes.IndexByte(p[x:], ']')
+	if y < 0 {
+		return "", "", ""
+	}
+	level = string(p[x+1 : x+y])
+
+	rest := bytes.TrimSpace(p[x+y+1:])
+	if z := bytes.Index(rest, []byte(": ")); z > 0 && bytes.IndexByte(rest[:z], ' ') < 0 {
+		subsystem = string(rest[:z])
+		rest = rest[z+2:]
+	}
+	return level, subsystem, string(rest)
+}
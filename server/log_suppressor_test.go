@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitLogLine(t *testing.T) {
+	cases := []struct {
+		Line      string
+		Level     string
+		Subsystem string
+		Message   string
+	}{
+		{
+			"2017/03/01 10:00:00.000000 [INFO] http: Shutting down\n",
+			"INFO", "http", "Shutting down",
+		},
+		{
+			"[ERR] mayaserver: failed: with colon",
+			"ERR", "mayaserver", "failed: with colon",
+		},
+		{
+			"[WARN] no subsystem here: really",
+			"WARN", "", "no subsystem here: really",
+		},
+		{
+			"no level",
+			"", "", "",
+		},
+	}
+
+	for _, tc := range cases {
+		level, subsystem, message := splitLogLine([]byte(tc.Line))
+		if level != tc.Level || subsystem != tc.Subsystem || message != tc.Message {
+			t.Fatalf("line: %q\ngot: %q, %q, %q", tc.Line, level, subsystem, message)
+		}
+	}
+}
+
+func TestLogSuppressor(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogSuppressor(&buf, &LogSuppression{
+		Enabled: true,
+		Window:  time.Hour,
+		Subsystems: map[string]time.Duration{
+			"http": 0,
+		},
+	}, nil)
+	defer l.Stop()
+
+	for i := 0; i < 5; i++ {
+		l.Write([]byte(fmt.Sprintf("2017/03/01 10:00:0%d.000000 [WARN] mayaserver: replica flapping\n", i)))
+		l.Write([]byte("[DEBUG] http: Request /foo\n"))
+	}
+	l.Write([]byte("[WARN] mayaserver: another line\n"))
+	l.Write([]byte("no level\n"))
+	l.Write([]byte("no level\n"))
+
+	out := buf.String()
+	if n := strings.Count(out, "replica flapping"); n != 1 {
+		t.Fatalf("expected 1 line, got %d:\n%s", n, out)
+	}
+	if n := strings.Count(out, "Request /foo"); n != 5 {
+		t.Fatalf("expected http lines to not be suppressed, got %d:\n%s", n, out)
+	}
+	if n := strings.Count(out, "no level"); n != 2 {
+		t.Fatalf("expected lines without a level to not be suppressed, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, "another line") {
+		t.Fatalf("expected distinct line:\n%s", out)
+	}
+
+	// Pending notices are written on stop
+	l.Stop()
+	expect := "[WARN] mayaserver: suppressed 4 duplicate(s) of: replica flapping"
+	if !strings.Contains(buf.String(), expect) {
+		t.Fatalf("expected %q:\n%s", expect, buf.String())
+	}
+	if strings.Contains(buf.String(), "duplicate(s) of: another line") {
+		t.Fatalf("unexpected notice for a line without duplicates:\n%s", buf.String())
+	}
+}
+
+func TestLogSuppressor_WindowExpiry(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogSuppressor(&buf, &LogSuppression{
+		Enabled: true,
+		Window:  time.Hour,
+	}, nil)
+	defer l.Stop()
+
+	l.Write([]byte("[ERR] mayaserver: oops\n"))
+	l.Write([]byte("[ERR] mayaserver: oops\n"))
+
+	// Nothing to flush while the window is open
+	l.Flush()
+	if strings.Contains(buf.String(), "suppressed") {
+		t.Fatalf("unexpected notice:\n%s", buf.String())
+	}
+
+	// Expire the window
+	l.Lock()
+	l.flush(time.Now().Add(2 * time.Hour))
+	l.Unlock()
+
+	expect := "[ERR] mayaserver: suppressed 1 duplicate(s) of: oops"
+	if !strings.Contains(buf.String(), expect) {
+		t.Fatalf("expected %q:\n%s", expect, buf.String())
+	}
+
+	// The line is logged again in a new window
+	l.Write([]byte("[ERR] mayaserver: oops\n"))
+	if n := strings.Count(buf.String(), "mayaserver: oops"); n != 2 {
+		t.Fatalf("expected 2 lines, got %d:\n%s", n, buf.String())
+	}
+}
+
+func TestLogSuppressor_FlushInterval(t *testing.T) {
+	cases := []struct {
+		Window     time.Duration
+		Subsystems map[string]time.Duration
+		Interval   time.Duration
+	}{
+		{time.Minute, nil, time.Minute},
+		{0, nil, 0},
+		{0, map[string]time.Duration{"http": 10 * time.Second}, 10 * time.Second},
+		{time.Minute, map[string]time.Duration{"http": 10 * time.Second, "raft": 0}, 10 * time.Second},
+		{time.Minute, map[string]time.Duration{"http": time.Hour}, time.Minute},
+	}
+
+	for _, tc := range cases {
+		l := &LogSuppressor{window: tc.Window, subsystems: tc.Subsystems}
+		if interval := l.flushInterval(); interval != tc.Interval {
+			t.Fatalf("window: %v, subsystems: %v, expected %v, got %v",
+				tc.Window, tc.Subsystems, tc.Interval, interval)
+		}
+	}
+}
+
+func TestLogSuppressor_SubsystemFlush(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogSuppressor(&buf, &LogSuppression{
+		Enabled: true,
+		Subsystems: map[string]time.Duration{
+			"http": 20 * time.Millisecond,
+		},
+	}, nil)
+	defer l.Stop()
+
+	l.Write([]byte("[ERR] http: oops\n"))
+	l.Write([]byte("[ERR] http: oops\n"))
+
+	// The notice is flushed without waiting for Stop
+	expect := "[ERR] http: suppressed 1 duplicate(s) of: oops"
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		l.Lock()
+		out := buf.String()
+		l.Unlock()
+		if strings.Contains(out, expect) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q:\n%s", expect, out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLogSuppressor_ExpiredOnWrite(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogSuppressor(&buf, &LogSuppression{
+		Enabled: true,
+		Window:  time.Hour,
+	}, nil)
+	defer l.Stop()
+
+	l.Write([]byte("[ERR] mayaserver: oops\n"))
+	l.Write([]byte("[ERR] mayaserver: oops\n"))
+
+	// Expire the window without flushing
+	l.Lock()
+	for _, sl := range l.seen {
+		sl.expires = time.Now().Add(-time.Second)
+	}
+	l.Unlock()
+
+	// Writing other lines leaves the expired window to the periodic flush
+	l.Write([]byte("[ERR] mayaserver: other\n"))
+	if strings.Contains(buf.String(), "suppressed") {
+		t.Fatalf("unexpected notice:\n%s", buf.String())
+	}
+
+	// Writing the line again writes its pending notice first
+	l.Write([]byte("[ERR] mayaserver: oops\n"))
+	out := buf.String()
+	notice := strings.Index(out, "suppressed 1 duplicate(s) of: oops")
+	if notice < 0 || notice > strings.LastIndex(out, "mayaserver: oops") {
+		t.Fatalf("expected notice before the line:\n%s", out)
+	}
+	if n := strings.Count(out, "mayaserver: oops"); n != 2 {
+		t.Fatalf("expected 2 lines, got %d:\n%s", n, out)
+	}
+}
+
+func BenchmarkLogSuppressor_DistinctLines(b *testing.B) {
+	l := NewLogSuppressor(ioutil.Discard, &LogSuppression{
+		Enabled: true,
+		Window:  time.Hour,
+	}, nil)
+	defer l.Stop()
+
+	// Fill the window, like HTTP logs carrying a request ID per line do
+	for i := 0; i < 20000; i++ {
+		l.Write([]byte(fmt.Sprintf("[DEBUG] http: Request /foo, request id: %d\n", i)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Write([]byte(fmt.Sprintf("[DEBUG] http: Request /bar, request id: %d\n", i)))
+	}
+}
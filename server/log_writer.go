@@ -62,6 +62,19 @@ func (l *LogWriter) DeregisterHandler(lh LogHandler) {
 	delete(l.handlers, lh)
 }
 
+// Logs returns the buffered logs, oldest first
+func (l *LogWriter) Logs() []string {
+	l.Lock()
+	defer l.Unlock()
+
+	logs := make([]string, 0, len(l.logs))
+	if l.logs[l.index] != "" {
+		logs = append(logs, l.logs[l.index:]...)
+	}
+	logs = append(logs, l.logs[:l.index]...)
+	return logs
+}
+
 // Write is used to accumulate new logs
 func (l *LogWriter) Write(p []byte) (n int, err error) {
 	l.Lock()
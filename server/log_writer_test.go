@@ -1,6 +1,7 @@
 package server
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -49,3 +50,23 @@ func TestLogWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestLogWriter_Logs(t *testing.T) {
+	w := NewLogWriter(3)
+	if logs := w.Logs(); len(logs) != 0 {
+		t.Fatalf("expected no logs, got: %v", logs)
+	}
+
+	w.Write([]byte("one\n"))
+	w.Write([]byte("two"))
+	if logs := w.Logs(); !reflect.DeepEqual(logs, []string{"one", "two"}) {
+		t.Fatalf("bad: %v", logs)
+	}
+
+	// The oldest logs are dropped once the buffer wraps around
+	w.Write([]byte("three"))
+	w.Write([]byte("four"))
+	if logs := w.Logs(); !reflect.DeepEqual(logs, []string{"two", "three", "four"}) {
+		t.Fatalf("bad: %v", logs)
+	}
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"net"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -14,6 +16,60 @@ const (
 	AnyZone = "any-zone"
 )
 
+// metaDataKeys are the meta-data categories served by Maya server. These
+// are listed when the meta-data root is requested. They are built from
+// metaDataRoutes in init, as metaIndex is one of the routes.
+var metaDataKeys []string
+
+func init() {
+	metaDataKeys = metaDataIndex(metaDataRoutes)
+}
+
+// metaDataRoute is an endpoint served under /latest/meta-data. Response is
+// a sample of what the endpoint returns & is used to document it.
+type metaDataRoute struct {
+	Path     string
+	Summary  string
+	Handler  func(s *HTTPServer, resp http.ResponseWriter, req *http.Request) (interface{}, error)
+	Response interface{}
+}
+
+// metaDataRoutes are the meta-data endpoints, matched by their exact path.
+var metaDataRoutes = []metaDataRoute{
+	{"/", "List the meta-data categories", (*HTTPServer).metaIndex, []string{}},
+	{"/cluster-id", "ID of this Maya installation", (*HTTPServer).metaClusterID, ""},
+	{"/health", "Liveness probe", (*HTTPServer).metaHealth, ""},
+	{"/instance-id", "Instance ID of this node", (*HTTPServer).metaInstanceID, ""},
+	{"/local-ipv4", "Local IPv4 address of this node", (*HTTPServer).metaLocalIPv4, ""},
+	{"/node-id", "ID of this Maya server node", (*HTTPServer).metaNodeID, ""},
+	{"/placement/availability-zone", "Availability zone of this node", (*HTTPServer).metaAvailabilityZone, ""},
+	{"/ready", "Readiness probe", (*HTTPServer).metaReady, ""},
+}
+
+// metaDataIndex returns the sorted categories of the given routes, i.e. the
+// first segment of their path. Categories with sub-paths end with a slash,
+// e.g. placement/.
+func metaDataIndex(routes []metaDataRoute) []string {
+	seen := make(map[string]struct{})
+	keys := []string{}
+	for _, route := range routes {
+		path := strings.TrimPrefix(route.Path, "/")
+		if path == "" {
+			continue
+		}
+		if i := strings.Index(path, "/"); i >= 0 {
+			path = path[:i+1]
+		}
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+		keys = append(keys, path)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (s *HTTPServer) MetaSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	path := strings.TrimPrefix(req.URL.Path, "/latest/meta-data")
 
@@ -22,15 +78,71 @@ func (s *HTTPServer) MetaSpecificRequest(resp http.ResponseWriter, req *http.Req
 		return nil, CodedError(405, ErrInvalidMethod)
 	}
 
-	// We do an exact suffix comparision
-	switch {
-	case strings.Compare(path, "/instance-id") == 0:
-		return s.metaInstanceID(resp, req)
-	case strings.Compare(path, "/placement/availability-zone") == 0:
-		return s.metaAvailabilityZone(resp, req)
-	default:
+	// We do an exact path comparision
+	for _, route := range metaDataRoutes {
+		if route.Path == path {
+			return route.Handler(s, resp, req)
+		}
+	}
+
+	return nil, CodedError(405, ErrInvalidMethod)
+}
+
+// metaIndex lists the meta-data categories, similar to the listing
+// returned by EC2 at the meta-data root.
+func (s *HTTPServer) metaIndex(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	return metaDataKeys, nil
+}
+
+// metaClusterID returns the persistent ID of this Maya installation.
+func (s *HTTPServer) metaClusterID(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	return s.maya.ClusterID(), nil
+}
+
+// metaNodeID returns the persistent ID of this Maya server node.
+func (s *HTTPServer) metaNodeID(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	return s.maya.NodeID(), nil
+}
+
+// metaHealth is the liveness probe of Maya server. It succeeds as long as
+// the HTTP server is serving requests.
+func (s *HTTPServer) metaHealth(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	return "ok", nil
+}
+
+// metaReady is the readiness probe of Maya server. It fails with a 503 if
+// Maya server can not serve requests.
+func (s *HTTPServer) metaReady(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
 		return nil, CodedError(405, ErrInvalidMethod)
 	}
+
+	if err := s.maya.Ready(); err != nil {
+		return nil, CodedError(503, err.Error())
+	}
+
+	return "ok", nil
 }
 
 // EBS demands a particular instance id to be returned during
@@ -41,14 +153,47 @@ func (s *HTTPServer) metaInstanceID(resp http.ResponseWriter, req *http.Request)
 		return nil, CodedError(405, ErrInvalidMethod)
 	}
 
+	if id := s.maya.config.MetaData.InstanceID; id != "" {
+		return id, nil
+	}
+
 	return AnyInstance, nil
 }
 
+// metaLocalIPv4 returns the configured local ipv4 address. It defaults to
+// the host portion of the advertised HTTP address, if that is an IPv4
+// address. Otherwise there is no local ipv4 address to serve.
+func (s *HTTPServer) metaLocalIPv4(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	if ip := s.maya.config.MetaData.LocalIPv4; ip != "" {
+		return ip, nil
+	}
+
+	host, _, err := net.SplitHostPort(s.maya.config.AdvertiseAddrs.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || ip.To4() == nil {
+		return nil, CodedError(404, "No local IPv4 address")
+	}
+
+	return host, nil
+}
+
 func (s *HTTPServer) metaAvailabilityZone(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 
 	if req.Method != "GET" {
 		return nil, CodedError(405, ErrInvalidMethod)
 	}
 
+	if zone := s.maya.config.MetaData.AvailabilityZone; zone != "" {
+		return zone, nil
+	}
+
 	return AnyZone, nil
 }
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"reflect"
 	"testing"
 
 	"github.com/ugorji/go/codec"
@@ -389,3 +391,240 @@ func TestInvalidReqMetaInstanceIDViaWrap(t *testing.T) {
 		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", ErrInvalidMethod, string(actual))
 	}
 }
+
+func TestMetaIndex(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	keys, ok := out.([]string)
+	if !ok || len(keys) == 0 {
+		t.Fatalf("Service must return the meta-data categories, got: %v", out)
+	}
+
+	// The categories are those of the routes
+	expected := []string{"cluster-id", "health", "instance-id", "local-ipv4", "node-id", "placement/", "ready"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, keys)
+	}
+}
+
+func TestMetaDataIndex(t *testing.T) {
+	routes := []metaDataRoute{
+		{Path: "/"},
+		{Path: "/b/two"},
+		{Path: "/a"},
+		{Path: "/b/one"},
+	}
+	expected := []string{"a", "b/"}
+	if keys := metaDataIndex(routes); !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, keys)
+	}
+}
+
+func TestMetaInstanceIDFromConfig(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.MetaData.InstanceID = "i-maya01"
+	})
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/instance-id", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != "i-maya01" {
+		t.Fatalf("ERR: expected: %v, got: %v", "i-maya01", out)
+	}
+}
+
+func TestMetaAvailZoneFromConfig(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.MetaData.AvailabilityZone = "bang-east-1a"
+	})
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/placement/availability-zone", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != "bang-east-1a" {
+		t.Fatalf("ERR: expected: %v, got: %v", "bang-east-1a", out)
+	}
+}
+
+func TestMetaLocalIPv4(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/local-ipv4", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	// Defaults to the advertised http address
+	if out != "127.0.0.1" {
+		t.Fatalf("ERR: expected: %v, got: %v", "127.0.0.1", out)
+	}
+
+	s.Maya.config.MetaData.LocalIPv4 = "10.0.0.10"
+	out, err = s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != "10.0.0.10" {
+		t.Fatalf("ERR: expected: %v, got: %v", "10.0.0.10", out)
+	}
+}
+
+func TestMetaLocalIPv4NotIPv4(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/local-ipv4", nil)
+
+	// An IPv6 or hostname advertise address is not served as local-ipv4
+	for _, addr := range []string{"[::1]:5656", "maya.example.com:5656"} {
+		s.Maya.config.AdvertiseAddrs.HTTP = addr
+		out, err := s.Server.MetaSpecificRequest(resp, req)
+
+		if err == nil {
+			t.Fatalf("addr: %s, ERR: expected error, got: %v", addr, out)
+		}
+
+		if coded, ok := err.(HTTPCodedError); !ok || coded.Code() != 404 {
+			t.Fatalf("addr: %s, ERR: expected a 404 error, got: %v", addr, err)
+		}
+	}
+}
+
+func TestInvalidReqMetaLocalIPv4(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/meta-data/local-ipv4", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err == nil {
+		t.Fatalf("ERR: expected: %v, got: %v", CodedError(405, ErrInvalidMethod), err)
+	}
+
+	if err.Error() != ErrInvalidMethod {
+		t.Fatalf("ERR: expected: %v, got: %v", ErrInvalidMethod, err.Error())
+	}
+
+	if out != nil {
+		t.Fatalf("Service must not return any value, for invalid request")
+	}
+}
+
+func TestMetaClusterAndNodeID(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/cluster-id", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != s.Maya.ClusterID() {
+		t.Fatalf("ERR: expected: %v, got: %v", s.Maya.ClusterID(), out)
+	}
+
+	req, _ = http.NewRequest("GET", "/latest/meta-data/node-id", nil)
+
+	out, err = s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != s.Maya.NodeID() {
+		t.Fatalf("ERR: expected: %v, got: %v", s.Maya.NodeID(), out)
+	}
+}
+
+func TestMetaHealth(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/health", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != "ok" {
+		t.Fatalf("ERR: expected: %v, got: %v", "ok", out)
+	}
+}
+
+func TestMetaReady(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/ready", nil)
+
+	out, err := s.Server.MetaSpecificRequest(resp, req)
+
+	if err != nil {
+		t.Fatalf("ERR: %v", err)
+	}
+
+	if out != "ok" {
+		t.Fatalf("ERR: expected: %v, got: %v", "ok", out)
+	}
+
+	// Not ready once the data_dir is gone
+	os.RemoveAll(s.Dir)
+
+	resp = httptest.NewRecorder()
+	s.Server.wrap(s.Server.MetaSpecificRequest)(resp, req)
+	if resp.Code != 503 {
+		t.Fatalf("err http resp code, expected: 503, got: %v", resp.Code)
+	}
+
+	// Not ready while shutting down
+	s.Maya.config.DataDir = ""
+	s.Maya.Shutdown()
+
+	resp = httptest.NewRecorder()
+	s.Server.wrap(s.Server.MetaSpecificRequest)(resp, req)
+	if resp.Code != 503 {
+		t.Fatalf("err http resp code, expected: 503, got: %v", resp.Code)
+	}
+}
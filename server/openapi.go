@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	// openAPIVersion is the version of the OpenAPI specification the API
+	// document conforms to.
+	openAPIVersion = "3.0.0"
+
+	// openAPIPath is where the API document is served.
+	openAPIPath = "/latest/openapi.json"
+)
+
+// openAPIDoc is an OpenAPI 3 document. Only the parts of the specification
+// needed to describe Maya server's API are modelled.
+type openAPIDoc struct {
+	OpenAPI string                     `json:"openapi"`
+	Info    openAPIInfo                `json:"info"`
+	Paths   map[string]openAPIPathItem `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIPathItem struct {
+	Get *openAPIOperation `json:"get,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary   string                     `json:"summary"`
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type       string                    `json:"type"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+}
+
+// OpenAPIRequest serves the OpenAPI document of the HTTP API.
+func (s *HTTPServer) OpenAPIRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	return buildOpenAPIDoc(s.maya.config.Version), nil
+}
+
+// buildOpenAPIDoc describes the HTTP API. The meta-data endpoints are
+// taken from the routes MetaSpecificRequest dispatches to, so that the
+// document can not get out of sync with them.
+func buildOpenAPIDoc(version string) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "Maya server API",
+			Version: version,
+		},
+		Paths: make(map[string]openAPIPathItem),
+	}
+
+	for _, route := range metaDataRoutes {
+		doc.Paths["/latest/meta-data"+route.Path] = openAPIGet(route.Summary, route.Response)
+	}
+	doc.Paths[openAPIPath] = openAPIGet("OpenAPI document of this API", &openAPIDoc{})
+
+	return doc
+}
+
+// openAPIGet describes a GET endpoint returning JSON encoded values of the
+// same type as the given sample.
+func openAPIGet(summary string, sample interface{}) openAPIPathItem {
+	return openAPIPathItem{
+		Get: &openAPIOperation{
+			Summary: summary,
+			Responses: map[string]openAPIResponse{
+				"200": {
+					Description: "OK",
+					Content: map[string]openAPIMediaType{
+						"application/json": {Schema: openAPISchemaOf(reflect.TypeOf(sample))},
+					},
+				},
+				"default": {
+					Description: "Error, returned as plain text",
+				},
+			},
+		},
+	}
+}
+
+// openAPISchemaOf returns the schema of the JSON encoding of the given type.
+func openAPISchemaOf(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: openAPISchemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			schema.Properties[name] = openAPISchemaOf(f.Type)
+		}
+		return schema
+	default:
+		return &openAPISchema{Type: "object"}
+	}
+}
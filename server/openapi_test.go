@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPIRequest(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *MayaConfig) {
+		mc.Version = "0.2.0"
+	})
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", openAPIPath, nil)
+	s.Server.wrap(s.Server.OpenAPIRequest)(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got: %d", resp.Code)
+	}
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(resp.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if doc.OpenAPI != openAPIVersion || doc.Info.Version != "0.2.0" {
+		t.Fatalf("bad: %#v", doc)
+	}
+
+	// Every meta-data route is documented
+	for _, route := range metaDataRoutes {
+		if _, ok := doc.Paths["/latest/meta-data"+route.Path]; !ok {
+			t.Fatalf("expected %q to be documented, got: %v", route.Path, doc.Paths)
+		}
+	}
+	if _, ok := doc.Paths[openAPIPath]; !ok {
+		t.Fatalf("expected %q to be documented, got: %v", openAPIPath, doc.Paths)
+	}
+
+	index := doc.Paths["/latest/meta-data/"].Get.Responses["200"].Content["application/json"].Schema
+	if index.Type != "array" || index.Items.Type != "string" {
+		t.Fatalf("bad index schema: %#v", index)
+	}
+
+	// Other methods are refused
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", openAPIPath, nil)
+	s.Server.wrap(s.Server.OpenAPIRequest)(resp, req)
+	if resp.Code != 405 {
+		t.Fatalf("expected 405, got: %d", resp.Code)
+	}
+}
+
+func TestOpenAPISchemaOf(t *testing.T) {
+	type nested struct {
+		Name    string   `json:"name"`
+		Tags    []string `json:"tags,omitempty"`
+		Skipped string   `json:"-"`
+		Count   int
+		private bool
+	}
+
+	expected := &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"name":  {Type: "string"},
+			"tags":  {Type: "array", Items: &openAPISchema{Type: "string"}},
+			"Count": {Type: "integer"},
+		},
+	}
+	if schema := openAPISchemaOf(reflect.TypeOf(&nested{})); !reflect.DeepEqual(schema, expected) {
+		t.Fatalf("bad: %#v", schema)
+	}
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"os"
 	"sync"
 )
 
@@ -13,6 +16,16 @@ type MayaServer struct {
 	logger    *log.Logger
 	logOutput io.Writer
 
+	// clusterID & nodeID identify this installation & this node. These
+	// are persisted in data_dir. The cluster ID is taken from the config
+	// if set, so that all the servers of an installation share it.
+	clusterID string
+	nodeID    string
+
+	// crashReporter reports the panics of the HTTP handlers & of the
+	// goroutines started by Maya server. It may be nil.
+	crashReporter *CrashReporter
+
 	shutdown     bool
 	shutdownCh   chan struct{}
 	shutdownLock sync.Mutex
@@ -28,9 +41,86 @@ func NewMayaServer(config *MayaConfig, logOutput io.Writer) (*MayaServer, error)
 		shutdownCh: make(chan struct{}),
 	}
 
+	if err := ms.setupIDs(); err != nil {
+		return nil, err
+	}
+
 	return ms, nil
 }
 
+// setupIDs loads the cluster & node IDs from data_dir, generating them on
+// the first run. A cluster_id set in the config takes precedence over the
+// persisted one & replaces it.
+func (ms *MayaServer) setupIDs() error {
+	if ms.config.DataDir == "" {
+		ms.logger.Println("[WARN] mayaserver: no data_dir set, cluster & node IDs will not persist")
+	}
+
+	clusterID := ms.config.ClusterID
+	if clusterID != "" {
+		if err := persistID(ms.config.DataDir, clusterIDFile, clusterID); err != nil {
+			return fmt.Errorf("failed to setup cluster ID: %v", err)
+		}
+	} else {
+		var err error
+		clusterID, err = loadOrCreateID(ms.config.DataDir, clusterIDFile)
+		if err != nil {
+			return fmt.Errorf("failed to setup cluster ID: %v", err)
+		}
+		ms.logger.Printf("[WARN] mayaserver: no cluster_id set, using cluster ID %s which is local to this server. "+
+			"Set cluster_id to the same value on all the servers of this installation", clusterID)
+	}
+
+	nodeID, err := loadOrCreateID(ms.config.DataDir, nodeIDFile)
+	if err != nil {
+		return fmt.Errorf("failed to setup node ID: %v", err)
+	}
+
+	ms.clusterID = clusterID
+	ms.nodeID = nodeID
+	return nil
+}
+
+// SetCrashReporter sets the CrashReporter used to report the panics of the
+// HTTP handlers & of the goroutines started by Maya server. It must be set
+// before the HTTP server is created.
+func (ms *MayaServer) SetCrashReporter(c *CrashReporter) {
+	ms.crashReporter = c
+}
+
+// ClusterID returns the ID of the installation this Maya server belongs to.
+func (ms *MayaServer) ClusterID() string {
+	return ms.clusterID
+}
+
+// NodeID returns the ID of this Maya server node.
+func (ms *MayaServer) NodeID() string {
+	return ms.nodeID
+}
+
+// Ready returns an error if Maya server can not serve requests, i.e. if it
+// is shutting down or if its data_dir is not writable.
+func (ms *MayaServer) Ready() error {
+	ms.shutdownLock.Lock()
+	shutdown := ms.shutdown
+	ms.shutdownLock.Unlock()
+	if shutdown {
+		return fmt.Errorf("mayaserver is shutting down")
+	}
+
+	if ms.config.DataDir == "" {
+		return nil
+	}
+
+	f, err := ioutil.TempFile(ms.config.DataDir, ".ready")
+	if err != nil {
+		return fmt.Errorf("data_dir is not writable: %v", err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
 // Shutdown is used to terminate MayaServer.
 func (ms *MayaServer) Shutdown() error {
 	ms.shutdownLock.Lock()
@@ -121,3 +121,51 @@ func TestMayaServerConfig(t *testing.T) {
 	}
 
 }
+
+func TestMayaServer_IDs(t *testing.T) {
+	dir, maya := makeMayaServer(t, nil)
+	defer os.RemoveAll(dir)
+	defer maya.Shutdown()
+
+	if maya.ClusterID() == "" || maya.NodeID() == "" {
+		t.Fatalf("expected cluster & node IDs, got: %q, %q", maya.ClusterID(), maya.NodeID())
+	}
+
+	// IDs persist across restarts with the same data dir
+	again, err := NewMayaServer(maya.config, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer again.Shutdown()
+
+	if again.ClusterID() != maya.ClusterID() {
+		t.Fatalf("expected cluster ID %q, got: %q", maya.ClusterID(), again.ClusterID())
+	}
+	if again.NodeID() != maya.NodeID() {
+		t.Fatalf("expected node ID %q, got: %q", maya.NodeID(), again.NodeID())
+	}
+
+	// The configured cluster ID takes precedence & is persisted
+	conf := *maya.config
+	conf.ClusterID = "maya-prod"
+	configured, err := NewMayaServer(&conf, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer configured.Shutdown()
+
+	if configured.ClusterID() != "maya-prod" {
+		t.Fatalf("expected cluster ID maya-prod, got: %q", configured.ClusterID())
+	}
+	if configured.NodeID() != maya.NodeID() {
+		t.Fatalf("expected node ID %q, got: %q", maya.NodeID(), configured.NodeID())
+	}
+
+	persisted, err := loadOrCreateID(conf.DataDir, clusterIDFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if persisted != "maya-prod" {
+		t.Fatalf("expected persisted cluster ID maya-prod, got: %q", persisted)
+	}
+}
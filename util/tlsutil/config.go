@@ -0,0 +1,142 @@
+package tlsutil
+
+// This is an adaptation of Hashicorp's Nomad library.
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// Config used to create tls.Config
+type Config struct {
+	// VerifyIncoming is used to verify the authenticity of incoming
+	// connections. This means that TCP requests are forbidden, only
+	// allowing for TLS. TLS connections must match a provided certificate
+	// authority. This can be used to force client auth.
+	VerifyIncoming bool
+
+	// VerifyOutgoing is used to verify the authenticity of outgoing
+	// connections. This means that TLS requests are used, and TCP
+	// requests are not made. TLS connections must match a provided
+	// certificate authority, and the certificates must be valid for the
+	// hostname of the server.
+	VerifyOutgoing bool
+
+	// ServerName is used to override the hostname that is verified on
+	// outgoing connections.
+	ServerName string
+
+	// CAFile is a path to a certificate authority file. This is used with
+	// VerifyIncoming or VerifyOutgoing to verify the TLS connection.
+	CAFile string
+
+	// CertFile is used to provide a TLS certificate that is used for
+	// serving TLS connections. Must be provided to serve TLS connections.
+	CertFile string
+
+	// KeyFile is used to provide a TLS key that is used for serving TLS
+	// connections. Must be provided to serve TLS connections.
+	KeyFile string
+}
+
+// AppendCA opens and parses the CA file and adds the certificates to
+// the provided CertPool.
+func (c *Config) AppendCA(pool *x509.CertPool) error {
+	if c.CAFile == "" {
+		return nil
+	}
+
+	// Read the file
+	data, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return fmt.Errorf("Failed to read CA file: %v", err)
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("Failed to parse any CA certificates")
+	}
+
+	return nil
+}
+
+// KeyPair is used to open and parse a certificate and key file
+func (c *Config) KeyPair() (*tls.Certificate, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load cert/key pair: %v", err)
+	}
+	return &cert, err
+}
+
+// OutgoingTLSConfig generates a TLS configuration for outgoing
+// requests. It will return a nil config if this configuration should
+// not use TLS for outgoing connections.
+func (c *Config) OutgoingTLSConfig() (*tls.Config, error) {
+	if !c.VerifyOutgoing {
+		return nil, nil
+	}
+
+	// Create the tlsConfig. The certificate chain & the hostname of the
+	// servers are verified by crypto/tls.
+	tlsConfig := &tls.Config{
+		RootCAs:    x509.NewCertPool(),
+		ServerName: c.ServerName,
+	}
+
+	// Ensure we have a CA if VerifyOutgoing is set
+	if c.CAFile == "" {
+		return nil, fmt.Errorf("VerifyOutgoing set, and no CA certificate provided!")
+	}
+
+	// Parse the CA cert if any
+	if err := c.AppendCA(tlsConfig.RootCAs); err != nil {
+		return nil, err
+	}
+
+	// Add cert/key
+	cert, err := c.KeyPair()
+	if err != nil {
+		return nil, err
+	} else if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// IncomingTLSConfig generates a TLS configuration for incoming requests
+func (c *Config) IncomingTLSConfig() (*tls.Config, error) {
+	// Create the tlsConfig
+	tlsConfig := &tls.Config{
+		ClientCAs:  x509.NewCertPool(),
+		ClientAuth: tls.NoClientCert,
+	}
+
+	// Parse the CA cert if any
+	if err := c.AppendCA(tlsConfig.ClientCAs); err != nil {
+		return nil, err
+	}
+
+	// A cert/key pair is mandatory to serve TLS connections
+	cert, err := c.KeyPair()
+	if err != nil {
+		return nil, err
+	} else if cert == nil {
+		return nil, fmt.Errorf("Cert & key files must be provided to serve TLS connections")
+	}
+	tlsConfig.Certificates = []tls.Certificate{*cert}
+
+	// Check if we require verification
+	if c.VerifyIncoming {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.CAFile == "" {
+			return nil, fmt.Errorf("VerifyIncoming set, and no CA certificate provided!")
+		}
+	}
+
+	return tlsConfig, nil
+}
@@ -5,6 +5,10 @@ package main
 var GitCommit string
 var GitDescribe string
 
+// The date this binary was built at.
+// This will be filled in by the compiler.
+var BuildDate string
+
 // The latest git tag will be filled in by the compiler
 var Version string = "none"
 